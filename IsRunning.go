@@ -7,8 +7,18 @@ func (h *GoRun) IsRunning() bool {
 	return h.isRunning
 }
 
-// GetPID returns the process ID if the program is running, otherwise returns 0
+// GetPID returns the process ID if the program is running, otherwise returns 0.
+// In a sandbox or under the ParentDeathSignal subreaper, it is the program itself.
 func (h *GoRun) GetPID() int {
+	if pid := h.processPID(); pid != 0 {
+		return h.programPID(pid)
+	}
+	return 0
+}
+
+// processPID returns the PID of the started process of the active instance, 0 if
+// not running: the init process of its sandbox, if any
+func (h *GoRun) processPID() int {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
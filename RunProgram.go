@@ -2,15 +2,40 @@ package gorun
 
 import (
 	"fmt"
-	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 func (h *GoRun) RunProgram() error {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
+	h.generation++
+	return h.runProgramUnsafe()
+}
+
+// runProgramUnsafe starts the program replacing the running one, if any. The mutex
+// is released while waiting for readiness: Status reports the instance as starting.
+// Should only be called when lifecycle and mutex are already held
+func (h *GoRun) runProgramUnsafe() error {
+	if h.proxy != nil {
+		if err := h.proxy.start(); err != nil {
+			return err
+		}
+	}
+
+	// Instances left behind by a gorun that crashed
+	h.stopOrphansUnsafe()
+
+	// Blue/green: keep the previous program serving until the new one is ready
+	if h.RestartStrategy == RestartBlueGreen && h.isRunning && h.Cmd != nil && h.Cmd.Process != nil {
+		return h.restartBlueGreenUnsafe()
+	}
 
 	// Always stop any previous running program first
 	// Use cleanup if KillAllOnStop is enabled
@@ -24,59 +49,205 @@ func (h *GoRun) RunProgram() error {
 		}
 	}
 
+	h.stopRequested.Store(false)
+
+	// Make sure no leftover process holds the program ports
+	if err := h.freePortsUnsafe(); err != nil {
+		return err
+	}
+
+	ports, err := h.nextPortsUnsafe(false)
+	if err != nil {
+		return err
+	}
+	h.setPorts(ports)
+
+	cmd, exit, err := h.startCmdUnsafe(ports)
+	if err != nil {
+		// Clean up the failed command to prevent issues in subsequent operations
+		h.Cmd = nil
+		h.exit = nil
+		h.isRunning = false
+		return err
+	}
+
+	h.Cmd = cmd
+	h.exit = exit
+	h.isRunning = true
+	h.attached = false
+	h.startTime = time.Now()
+
+	if err := h.waitReadyUnsafe(cmd, exit.done); err != nil {
+		h.stopProgramUnsafe()
+		return err
+	}
+	h.ready.set(true)
+	h.emit(Event{Type: EventReady, PID: h.programPID(cmd.Process.Pid)})
+
+	return nil
+}
+
+// startCmdUnsafe starts a new instance of the program without touching h.Cmd.
+// Its exit is published by the one goroutine reaping it.
+// Should only be called when mutex is already held
+func (h *GoRun) startCmdUnsafe(ports map[string]int) (*exec.Cmd, *processExit, error) {
 	runArgs := []string{}
 
 	if h.RunArguments != nil {
 		runArgs = h.RunArguments()
 	}
 
-	h.Cmd = exec.Command(h.ExecProgramPath, runArgs...)
-	h.hasWaited = false // Reset wait flag for new process
+	runArgs, err := expandAllPorts(runArgs, ports)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid run argument %v", err)
+	}
+
+	path, args, err := h.debugCommand(h.ExecProgramPath, runArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := exec.Command(path, args...)
+
+	if len(h.Env) > 0 {
+		env, err := expandAllPorts(h.Env, ports)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid environment variable %v", err)
+		}
+		cmd.Env = append(os.Environ(), env...)
+	}
 
-	// DEBUG: Log the exact run command being executed
-	// fmt.Fprintf(h.safeBuffer, "[GORUN DEBUG] Starting: %s %v\n", h.ExecProgramPath, runArgs)
-	// fmt.Fprintf(h.safeBuffer, "[GORUN DEBUG] Working dir: %s\n", h.WorkingDir)
+	coverDir, err := h.setupCoverage(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	started := false
+	defer func() {
+		// Only a started instance merges its coverage data
+		if coverDir != "" && !started {
+			os.RemoveAll(coverDir)
+		}
+	}()
 
 	// Set working directory if specified
 	if h.WorkingDir != "" {
-		h.Cmd.Dir = h.WorkingDir
+		cmd.Dir = h.WorkingDir
 	}
 
-	stderr, err := h.Cmd.StderrPipe()
-	if err != nil {
-		return err
+	// Let exec copy the output so Wait only returns once it has been fully read.
+	// Both go through one pipe: a crash report is read in the order it was written.
+	crashes := newCrashDetector(h.safeBuffer, func(crash *Crash) {
+		pid := h.programPID(cmd.Process.Pid)
+		h.emit(Event{Type: EventCrash, PID: pid, Crash: crash})
+		h.recordRace(pid, crash)
+	})
+	cmd.Stdout = crashes
+	cmd.Stderr = crashes
+	// Don't let grandchildren holding the output open block Wait forever
+	cmd.WaitDelay = time.Second
+
+	if err := h.Limits.validate(); err != nil {
+		return nil, nil, err
 	}
 
-	stdout, err := h.Cmd.StdoutPipe()
+	if err := h.applyCredentials(cmd); err != nil {
+		return nil, nil, err
+	}
+	if err := h.applyParentDeath(cmd); err != nil {
+		return nil, nil, err
+	}
+
+	// Runs the init process of the sandbox (or the subreaper of ParentDeathSignal) instead,
+	// with the credentials moved to the program
+	sb, err := h.setupSandbox(cmd)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	// Otherwise gorun's own executable holds the program until its limits are applied
+	var gate *limitsGate
+	if sb == nil {
+		if gate, err = setupLimitsGate(cmd, h.Limits); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	err = h.Cmd.Start()
+	cg, err := h.setupCgroup(cmd)
 	if err != nil {
-		// DEBUG: Log start failure details
-		// fmt.Fprintf(h.safeBuffer, "[GORUN DEBUG] Failed to start process: %v\n", err)
-		// Clean up the failed command to prevent issues in subsequent operations
-		h.Cmd = nil
-		h.isRunning = false
-		h.hasWaited = false
-		return err
+		if sb != nil {
+			sb.close()
+		}
+		if gate != nil {
+			gate.close()
+		}
+		return nil, nil, err
+	}
+
+	exit := newProcessExit()
+	if err := startProcess(cmd, h.NoNewPrivs, exit.done); err != nil {
+		if cg != nil {
+			cg.remove()
+		}
+		if sb != nil {
+			sb.close()
+		}
+		if gate != nil {
+			gate.close()
+		}
+		return nil, nil, err
+	}
+	startTime := time.Now()
+	if cg != nil {
+		cg.started()
+	}
+	if sb != nil {
+		sb.started()
+	}
+	if gate != nil {
+		gate.started()
 	}
 
-	// DEBUG: Log successful start
-	// fmt.Fprintf(h.safeBuffer, "[GORUN DEBUG] Process started successfully with PID: %d\n", h.Cmd.Process.Pid)
+	abort := func(err error) (*exec.Cmd, *processExit, error) {
+		cmd.Process.Kill()
+		cmd.Wait()
+		// Never an instance: nobody observes the exit, this only releases startProcess
+		close(exit.done)
+		if cg != nil {
+			cg.remove()
+		}
+		if sb != nil {
+			sb.close()
+		}
+		if gate != nil {
+			gate.close()
+		}
+		return nil, nil, err
+	}
 
-	h.isRunning = true
+	if err := applyLimits(cmd.Process.Pid, h.Limits); err != nil {
+		// Never leave a process running without the limits it was configured with
+		return abort(err)
+	}
+	// The program only starts now, with the limits already applied
+	if sb != nil {
+		if err := sb.start(cmd.Process.Pid); err != nil {
+			return abort(err)
+		}
+		h.trackSandbox(cmd.Process.Pid, sb)
+	}
+	if gate != nil {
+		if err := gate.start(); err != nil {
+			return abort(err)
+		}
+	}
+	// The program itself, not the init process of its sandbox
+	pid := h.programPID(cmd.Process.Pid)
+	h.trackProcessPorts(cmd.Process.Pid, ports)
+	h.trackCgroup(cmd.Process.Pid, cg)
+	h.recordProcess(pid)
+	h.emit(Event{Type: EventStarted, PID: pid})
 
 	var once sync.Once
 	done := make(chan struct{})
 
-	// Create local references for goroutines to avoid race conditions
-	currentCmd := h.Cmd
-
-	go io.Copy(h.safeBuffer, stderr)
-	go io.Copy(h.safeBuffer, stdout)
-
 	go func() {
 		select {
 		case <-h.ExitChan:
@@ -88,28 +259,101 @@ func (h *GoRun) RunProgram() error {
 		}
 	}()
 
+	// The one waiter of cmd: everything else observes exit.done. cmd.Process holds the
+	// pidfd created with the process (Linux 5.3+), Signal, Kill and Wait go through it.
 	go func() {
-		err := currentCmd.Wait()
-		h.mutex.Lock()
-		h.isRunning = false
-		h.hasWaited = true // Mark that Wait() has been called
-		h.mutex.Unlock()
+		if h.ProcessGroup {
+			// Members left behind when the leader exits, eg: background jobs. Killed
+			// before the leader is reaped: its group id can't be reused meanwhile.
+			if waitExited(cmd.Process.Pid) == nil {
+				h.signalProcess(cmd.Process, syscall.SIGKILL)
+			}
+		}
+		err := cmd.Wait()
+
+		// The init process of a sandbox reports how the program itself exited
+		var sandboxed *sandboxExit
+		if sb != nil {
+			if sandboxed = sb.exit(); sandboxed != nil {
+				err = sandboxed.err()
+			}
+		}
 
 		if err != nil {
 			// Check if the error is due to a signal termination (normal shutdown)
 			errMsg := err.Error()
 			if !strings.Contains(errMsg, "signal: terminated") &&
 				!strings.Contains(errMsg, "signal: killed") &&
-				!strings.Contains(errMsg, "signal: interrupt") &&
-				!strings.Contains(errMsg, "waitid: no child processes") {
+				!strings.Contains(errMsg, "signal: interrupt") {
 				// This is an actual error, not a normal signal termination
 				h.safeBuffer.Write([]byte(fmt.Sprintf("App: %v closed with error: %v\n", h.ExecProgramPath, err)))
 			}
-			// No log for normal signal terminations or known benign waitid error
+			// No log for normal signal terminations
 		}
 		// No log for clean exits either
+
+		h.trackProcessPorts(cmd.Process.Pid, nil)
+		h.trackSandbox(cmd.Process.Pid, nil)
+		h.forgetProcess(pid)
+		h.killDebugTargets(cmd.Process.Pid)
+		oomKills := 0
+		if cg != nil {
+			// Read before removal, which also kills the descendants left behind
+			oomKills = cg.oomKills()
+			h.trackCgroup(cmd.Process.Pid, nil)
+			if err := cg.remove(); err != nil {
+				h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: %v\n", err)))
+			}
+		}
+		info := newExitInfo(cmd, startTime, err, h.stopRequested.Load())
+		if sandboxed != nil {
+			info.Code, info.Signal, info.Err = sandboxed.code, sandboxed.signal, err
+		}
+		info.PID = pid
+		info.Limit = limitExceeded(h.Limits, info, cmd.ProcessState)
+		if info.Limit == "" && oomKills > 0 && info.Signal == syscall.SIGKILL {
+			info.Limit = LimitMemoryMax
+		}
+		// A crash report only explains a failed exit: the program may print one itself.
+		// Data races were reported as they happened.
+		if fatal, lastRace := crashes.finish(); !info.Success() {
+			if fatal != nil {
+				info.Crash = fatal
+				h.emit(Event{Type: EventCrash, PID: pid, Crash: fatal})
+			} else if lastRace != nil {
+				info.Crash = lastRace
+			}
+		}
+		if coverDir != "" {
+			// Merged once the exit is published, CoverageProfile waits for it
+			h.addPendingCoverage()
+		}
+		// Emit before publishing so StopProgram returns after the event was delivered
+		h.emit(Event{Type: EventExited, PID: pid, Exit: info})
+		// Nothing above takes the mutex: StopProgram holds it while waiting for this
+		exit.publish(info)
+
+		h.mutex.Lock()
+		// A replaced instance (blue/green) must not clear the state of the active one,
+		// StopProgram may have applied the exit already
+		if h.applyExitUnsafe(cmd, exit) {
+			// Nobody asked for this exit: apply the RestartPolicy
+			if !h.stopRequested.Load() && h.RestartPolicy.ShouldRestart(info.Code) {
+				go h.autoRestart(h.generation)
+			}
+		}
+		h.mutex.Unlock()
+
+		if coverDir != "" {
+			if err := h.mergeCoverage(coverDir); err != nil {
+				h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: %v\n", err)))
+			}
+			h.donePendingCoverage()
+		}
+
 		once.Do(func() { close(done) })
 	}()
 
-	return nil
+	started = true
+	return cmd, exit, nil
 }
@@ -1,16 +1,17 @@
 package gorun
 
 import (
-	"fmt"
 	"os"
-	"runtime"
 	"syscall"
 	"time"
 )
 
 func (h *GoRun) StopProgram() error {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
+	h.generation++
 
 	if h.KillAllOnStop {
 		return h.stopProgramAndCleanupUnsafe(true)
@@ -18,85 +19,46 @@ func (h *GoRun) StopProgram() error {
 	return h.stopProgramUnsafe()
 }
 
-// stopProgramUnsafe stops the program without acquiring the mutex
+// stopProgramUnsafe stops the program without acquiring the mutex. It returns once
+// the program has been reaped, with its exit recorded in LastExit.
 // Should only be called when mutex is already held
 func (h *GoRun) stopProgramUnsafe() error {
-	if !h.isRunning || h.Cmd == nil || h.Cmd.Process == nil {
-		h.isRunning = false
-		return nil
-	}
+	// Stop routing traffic to the program before it goes away
+	h.ready.set(false)
+	// An exit from now on is expected, don't apply the RestartPolicy
+	h.stopRequested.Store(true)
 
-	// Check if process has already exited
-	if h.Cmd.ProcessState != nil && h.Cmd.ProcessState.Exited() {
+	if !h.isRunning || h.Cmd == nil || h.Cmd.Process == nil || h.exit == nil {
 		h.isRunning = false
 		return nil
 	}
 
-	// If Wait() has already been called, don't try to wait again
-	if h.hasWaited {
+	// The waiter of the process publishes its exit, which StopProgram applies itself:
+	// the waiter can't take the mutex held here
+	cmd, exit := h.Cmd, h.exit
+	err := h.terminateProcess(cmd.Process, exit.done)
+	if !h.applyExitUnsafe(cmd, exit) {
 		h.isRunning = false
-		return nil
 	}
+	return err
+}
 
-	process := h.Cmd.Process
-	h.isRunning = false
-
-	// Cross-platform graceful shutdown approach
-	if runtime.GOOS == "windows" {
-		// On Windows, we don't have SIGTERM, so we use Kill directly
-		if err := process.Kill(); err != nil {
-			if err.Error() == "os: process already finished" {
-				return nil
-			}
-			return err
-		}
-		return nil
+// stopTimeout returns the configured graceful stop timeout or the default
+func (h *GoRun) stopTimeout() time.Duration {
+	if h.StopTimeout > 0 {
+		return h.StopTimeout
 	}
+	return gracefulStopTimeout
+}
 
-	// On Unix-like systems (Linux, macOS), try graceful shutdown first
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		// If SIGTERM fails, it could be because the process already exited
-		// Check if it's an "os: process already finished" error
-		if err.Error() == "os: process already finished" {
-			h.isRunning = false
-			return nil
-		}
-		// For other errors, try force kill
-		if killErr := process.Kill(); killErr != nil {
-			if killErr.Error() == "os: process already finished" {
-				return nil
-			}
-			return killErr
-		}
-		return nil
+// stopSignal returns the configured graceful stop signal or SIGTERM. dlv stops
+// and kills the program on SIGINT, whatever the StopSignal of the program.
+func (h *GoRun) stopSignal() os.Signal {
+	if h.Debug != nil {
+		return os.Interrupt
 	}
-
-	// Wait a bit for graceful shutdown
-	done := make(chan error, 1)
-	go func() {
-		_, err := process.Wait()
-		h.hasWaited = true // Mark that Wait() has been called (mutex already held by parent)
-		done <- err
-	}()
-
-	select {
-	case <-time.After(3 * time.Second):
-		// Timeout reached, force kill
-		fmt.Fprintf(os.Stderr, "Process did not terminate gracefully, forcing kill\n")
-		if err := process.Kill(); err != nil {
-			// If kill fails with "process already finished", that's not an error
-			if err.Error() == "os: process already finished" {
-				return nil
-			}
-			return err
-		}
-		return nil
-	case err := <-done:
-		// Process terminated gracefully
-		// If we get "no child processes" error, it means the process already exited
-		if err != nil && (err.Error() == "waitid: no child processes" || err.Error() == "wait: no child processes") {
-			return nil // This is not an error, the process already exited
-		}
-		return err
+	if h.StopSignal != nil {
+		return h.StopSignal
 	}
+	return syscall.SIGTERM
 }
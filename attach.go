@@ -0,0 +1,100 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// errAttachedExit is the ExitInfo.Err of an attached process: only its parent gets its exit status
+var errAttachedExit = errors.New("exit status unknown, the process was attached")
+
+// Attach returns a GoRun managing process pid, started outside gorun (eg: by a
+// previous host). IsRunning, Status, Stats and StopProgram work as for a started
+// process, with the StopSignal and StopTimeout of c (nil for the defaults).
+// The output of an attached process is not captured and its exit status is
+// unknown: Attached reports true and its ExitInfo has Code -1 and Err set.
+// RunProgram replaces it with a process started from c. On Linux signals and
+// the exit are observed through a pidfd, they can't reach a reused PID.
+func Attach(pid int, c *Config) (*GoRun, error) {
+	if c == nil {
+		c = &Config{}
+	}
+	h := New(c)
+
+	handle, err := openProcessHandle(pid)
+	if err != nil {
+		return nil, fmt.Errorf("attach %d: %w", pid, err)
+	}
+	// Used by StopProgram, on Linux it signals through a pidfd too
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		handle.close()
+		return nil, fmt.Errorf("attach %d: %w", pid, err)
+	}
+	startTime, err := processStartTime(pid)
+	if err != nil {
+		startTime = time.Now()
+	}
+	cmd := &exec.Cmd{Process: process}
+	exit := newProcessExit()
+
+	h.mutex.Lock()
+	h.Cmd = cmd
+	h.exit = exit
+	h.isRunning = true
+	h.attached = true
+	h.startTime = startTime
+	h.ready.set(true)
+	h.mutex.Unlock()
+
+	h.safeBuffer.Write([]byte(fmt.Sprintf("Attached to PID %d, its output is not captured\n", pid)))
+	h.emit(Event{Type: EventReady, PID: pid})
+
+	var once sync.Once
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-h.ExitChan:
+			h.StopProgram()
+			once.Do(func() { close(done) })
+		case <-done:
+		}
+	}()
+
+	go func() {
+		handle.wait(0)
+		handle.close()
+
+		info := &ExitInfo{
+			PID:       pid,
+			Code:      -1,
+			Requested: h.stopRequested.Load(),
+			StartTime: startTime,
+			ExitTime:  time.Now(),
+			Err:       errAttachedExit,
+		}
+		h.emit(Event{Type: EventExited, PID: pid, Exit: info})
+		exit.publish(info)
+
+		h.mutex.Lock()
+		h.applyExitUnsafe(cmd, exit)
+		h.mutex.Unlock()
+
+		once.Do(func() { close(done) })
+	}()
+
+	return h, nil
+}
+
+// Attached reports whether the active process was attached rather than started,
+// its output is then not captured
+func (h *GoRun) Attached() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.isRunning && h.attached
+}
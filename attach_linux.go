@@ -0,0 +1,32 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// processStartTime returns when pid started, from /proc/<pid>/stat and the boot time
+func processStartTime(pid int) (time.Time, error) {
+	stat, err := readProcStat(pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			boot, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				break
+			}
+			ticks := time.Duration(stat.startTime) * time.Second / clockTicks
+			return time.Unix(boot, 0).Add(ticks), nil
+		}
+	}
+	return time.Time{}, errors.New("no btime in /proc/stat")
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"time"
+)
+
+// processStartTime is unknown without /proc, Attach uses the attach time instead
+func processStartTime(pid int) (time.Time, error) {
+	return time.Time{}, errors.New("process start time is only available on Linux")
+}
@@ -0,0 +1,135 @@
+package gorun
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startOutside starts a test program the way another tool would, reaped by the returned channel
+func startOutside(t *testing.T, programName string) (*exec.Cmd, <-chan struct{}) {
+	t.Helper()
+	execPath := buildTestProgram(t, programName)
+	t.Cleanup(func() { os.Remove(execPath) })
+
+	cmd := exec.Command(execPath)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start %s: %v", programName, err)
+	}
+	reaped := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(reaped)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-reaped
+	})
+	return cmd, reaped
+}
+
+func TestAttach_StopProgram(t *testing.T) {
+	cmd, reaped := startOutside(t, "long_program")
+
+	exits := make(chan *ExitInfo, 1)
+	gr, err := Attach(cmd.Process.Pid, &Config{
+		OnEvent: func(ev Event) {
+			if ev.Type == EventExited {
+				exits <- ev.Exit
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+
+	if !gr.IsRunning() || !gr.Attached() || gr.GetPID() != cmd.Process.Pid {
+		t.Fatalf("Expected a running attached process %d, got running=%v attached=%v pid=%d",
+			cmd.Process.Pid, gr.IsRunning(), gr.Attached(), gr.GetPID())
+	}
+	status := gr.Status()
+	if status.State != StateRunning || !status.Attached || status.StartTime.After(time.Now()) {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if !strings.Contains(gr.getOutput(), "output is not captured") {
+		t.Errorf("The missing output should be reported, got %q", gr.getOutput())
+	}
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	select {
+	case <-reaped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Attached process was not stopped")
+	}
+
+	select {
+	case exit := <-exits:
+		if !exit.Requested || exit.Code != -1 || exit.Err == nil {
+			t.Errorf("Unexpected exit info: %+v", exit)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No exit event")
+	}
+	if gr.IsRunning() || gr.Attached() {
+		t.Error("The stopped process should not be running or attached")
+	}
+}
+
+func TestAttach_ForcedStop(t *testing.T) {
+	cmd, reaped := startOutside(t, "stubborn_program")
+	time.Sleep(100 * time.Millisecond) // Let it ignore SIGTERM
+
+	gr, err := Attach(cmd.Process.Pid, &Config{StopTimeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	start := time.Now()
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	select {
+	case <-reaped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stubborn attached process was not killed")
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("The kill should follow the stop timeout, took %v", elapsed)
+	}
+}
+
+func TestAttach_ExitOnItsOwn(t *testing.T) {
+	cmd := exec.Command("sleep", "0.5")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not available")
+	}
+	go cmd.Wait()
+
+	gr, err := Attach(cmd.Process.Pid, nil)
+	if err != nil {
+		t.Fatalf("Attach() failed: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for gr.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if gr.IsRunning() {
+		t.Fatal("The exit of the attached process was not noticed")
+	}
+	if exit := gr.LastExit(); exit == nil || exit.Requested || exit.PID != cmd.Process.Pid {
+		t.Errorf("Unexpected last exit: %+v", exit)
+	}
+}
+
+func TestAttach_NoProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "0")
+	if err := cmd.Run(); err != nil {
+		t.Skip("sleep not available")
+	}
+	if _, err := Attach(cmd.Process.Pid, nil); err == nil {
+		t.Error("Attach() should fail for a process that is gone")
+	}
+}
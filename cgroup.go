@@ -0,0 +1,131 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// CgroupConfig places every started process in its own cgroup v2, created under
+// Parent and removed once the process exited. Unlike process groups, descendants
+// can't escape it (eg: with setsid): a forced stop kills the whole cgroup through
+// cgroup.kill, and anything left behind when the program exits is killed too.
+//
+// When the hierarchy is not writable (cgroups not delegated), a controller is
+// missing or the kernel is older than 5.14, gorun logs a warning and runs the
+// program without the cgroup or without the limit, unless Required is set.
+type CgroupConfig struct {
+	Parent    string  // cgroup v2 directory to create cgroups in (default: the cgroup of gorun itself)
+	MemoryMax uint64  // memory.max in bytes, 0 for no limit. Exceeding it OOM kills the program
+	CPUMax    float64 // cpu.max in CPUs, eg: 0.5 for half a core, 0 for no limit
+	PidsMax   int     // pids.max, 0 for no limit
+	Required  bool    // Make RunProgram fail instead of falling back
+}
+
+// LimitMemoryMax is the ExitInfo.Limit of a program killed by the OOM killer of its cgroup
+const LimitMemoryMax = "memory.max"
+
+// CgroupStats is a sample of the cgroup of the running program
+type CgroupStats struct {
+	Path          string        `json:"path"`
+	MemoryCurrent uint64        `json:"memory_current"` // memory.current, 0 without the memory controller
+	MemoryPeak    uint64        `json:"memory_peak"`    // memory.peak, 0 without the memory controller or before Linux 5.19
+	CPUUsage      time.Duration `json:"cpu_usage"`      // usage_usec of cpu.stat
+	Processes     int           `json:"processes"`      // Processes in the cgroup
+	OOMKills      int           `json:"oom_kills"`      // oom_kill of memory.events
+}
+
+// cgroup is the cgroup of one started process
+type cgroup struct {
+	path string
+	dir  *os.File // Open from attach until the process started
+}
+
+// setupCgroup creates the cgroup of cmd, if configured, and makes cmd start in it.
+// It returns nil when the program runs without cgroup.
+func (h *GoRun) setupCgroup(cmd *exec.Cmd) (*cgroup, error) {
+	if h.Cgroup == nil {
+		return nil, nil
+	}
+
+	warn := func(err error) {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: %v\n", err)))
+	}
+	cg, err := newCgroup(h.Cgroup, warn)
+	if err != nil {
+		return nil, fmt.Errorf("cgroup: %w", err)
+	}
+	if cg == nil {
+		return nil, nil
+	}
+
+	if err := cg.attach(cmd); err != nil {
+		cg.remove()
+		if h.Cgroup.Required {
+			return nil, fmt.Errorf("cgroup: %w", err)
+		}
+		warn(fmt.Errorf("running without cgroup: %w", err))
+		return nil, nil
+	}
+	return cg, nil
+}
+
+// trackCgroup records the cgroup of a live process, nil forgets it
+func (h *GoRun) trackCgroup(pid int, cg *cgroup) {
+	h.cgroupsMutex.Lock()
+	defer h.cgroupsMutex.Unlock()
+
+	if cg == nil {
+		delete(h.cgroups, pid)
+		return
+	}
+	if h.cgroups == nil {
+		h.cgroups = make(map[int]*cgroup)
+	}
+	h.cgroups[pid] = cg
+}
+
+func (h *GoRun) processCgroup(pid int) *cgroup {
+	h.cgroupsMutex.Lock()
+	defer h.cgroupsMutex.Unlock()
+	return h.cgroups[pid]
+}
+
+// killProcess force kills process, with everything in its cgroup when it has one,
+// the tree of its subreaper or its process group with ProcessGroup
+func (h *GoRun) killProcess(process *os.Process) error {
+	if cg := h.processCgroup(process.Pid); cg != nil {
+		if err := cg.kill(); err == nil {
+			return nil
+		}
+	}
+	if sb := h.processSandbox(process.Pid); sb != nil {
+		if err := sb.kill(); err == nil {
+			return nil
+		}
+	}
+	return h.signalProcess(process, os.Kill)
+}
+
+// CgroupPath returns the cgroup directory of the running program, empty if it has none
+func (h *GoRun) CgroupPath() string {
+	if cg := h.processCgroup(h.processPID()); cg != nil {
+		return cg.path
+	}
+	return ""
+}
+
+// CgroupStats samples the cgroup of the running program
+func (h *GoRun) CgroupStats() (CgroupStats, error) {
+	pid := h.processPID()
+	if pid == 0 {
+		return CgroupStats{}, ErrNotRunning
+	}
+	cg := h.processCgroup(pid)
+	if cg == nil {
+		return CgroupStats{}, errors.New("program is not running in a cgroup")
+	}
+	return cg.stats()
+}
@@ -0,0 +1,274 @@
+package gorun
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// cgroupSeq makes the cgroup names of the instances of one gorun process unique
+var cgroupSeq atomic.Uint64
+
+// cgroupRemoveTimeout bounds the wait for killed processes to leave a cgroup
+const cgroupRemoveTimeout = 2 * time.Second
+
+// cgroupLimit is a limit file of a cgroup and the controller providing it
+type cgroupLimit struct {
+	controller, file, value string
+}
+
+// newCgroup creates a cgroup under c.Parent and applies the limits of c. Problems
+// are returned when c.Required, otherwise passed to warn, and nil is returned if
+// the cgroup itself could not be created.
+func newCgroup(c *CgroupConfig, warn func(error)) (*cgroup, error) {
+	fallback := func(err error) (*cgroup, error) {
+		if c.Required {
+			return nil, err
+		}
+		warn(fmt.Errorf("running without cgroup: %w", err))
+		return nil, nil
+	}
+
+	parent := c.Parent
+	if parent == "" {
+		var err error
+		if parent, err = ownCgroupDir(); err != nil {
+			return fallback(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.procs")); err != nil {
+		return fallback(fmt.Errorf("%s is not a cgroup v2 directory", parent))
+	}
+
+	// Controllers must be enabled in the parent before creating the child
+	var limits []cgroupLimit
+	if c.MemoryMax > 0 {
+		limits = append(limits, cgroupLimit{"memory", "memory.max", strconv.FormatUint(c.MemoryMax, 10)})
+	}
+	if c.CPUMax > 0 {
+		const period = 100000
+		quota := int(c.CPUMax * period)
+		limits = append(limits, cgroupLimit{"cpu", "cpu.max", fmt.Sprintf("%d %d", max(quota, 1000), period)})
+	}
+	if c.PidsMax > 0 {
+		limits = append(limits, cgroupLimit{"pids", "pids.max", strconv.Itoa(c.PidsMax)})
+	}
+
+	var unavailable []error
+	for _, limit := range limits {
+		if err := enableController(parent, limit.controller); err != nil {
+			unavailable = append(unavailable, fmt.Errorf("%s not applied: %w", limit.file, err))
+		}
+	}
+	if len(unavailable) > 0 && c.Required {
+		return nil, errors.Join(unavailable...)
+	}
+
+	path := filepath.Join(parent, fmt.Sprintf("gorun-%d-%d", os.Getpid(), cgroupSeq.Add(1)))
+	if err := os.Mkdir(path, 0755); err != nil {
+		return fallback(err)
+	}
+	cg := &cgroup{path: path}
+
+	if _, err := os.Stat(filepath.Join(path, "cgroup.kill")); err != nil {
+		cg.remove()
+		return fallback(errors.New("cgroup.kill is not supported, Linux 5.14 or later is required"))
+	}
+
+	for _, limit := range limits {
+		if err := cg.write(limit.file, limit.value); err != nil && !os.IsNotExist(err) {
+			unavailable = append(unavailable, fmt.Errorf("%s not applied: %w", limit.file, err))
+		}
+	}
+	for _, err := range unavailable {
+		if c.Required {
+			cg.remove()
+			return nil, err
+		}
+		warn(err)
+	}
+	return cg, nil
+}
+
+// ownCgroupDir returns the cgroup v2 directory of the current process
+func ownCgroupDir() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var path string
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "0::"); ok {
+			path, found = rest, true
+		}
+	}
+	if !found {
+		return "", errors.New("no cgroup v2 hierarchy")
+	}
+
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(mount, path), nil
+}
+
+// cgroup2Mount returns where the cgroup v2 hierarchy is mounted, eg: /sys/fs/cgroup
+// or /sys/fs/cgroup/unified on hybrid systems
+func cgroup2Mount() (string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source options
+		fields := strings.Fields(scanner.Text())
+		sep := indexOf(fields, "-")
+		if sep > 4 && sep+1 < len(fields) && fields[sep+1] == "cgroup2" {
+			return fields[4], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no cgroup v2 hierarchy mounted")
+}
+
+// enableController makes controller available to the children of parent
+func enableController(parent, controller string) error {
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	if indexOf(strings.Fields(string(enabled)), controller) >= 0 {
+		return nil
+	}
+
+	available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	if indexOf(strings.Fields(string(available)), controller) < 0 {
+		return fmt.Errorf("controller %s is not available in %s", controller, parent)
+	}
+
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+controller), 0); err != nil {
+		if errors.Is(err, syscall.EBUSY) {
+			return fmt.Errorf("controller %s can't be enabled in %s, it has processes of its own: use a delegated Parent", controller, parent)
+		}
+		return err
+	}
+	return nil
+}
+
+func (cg *cgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(cg.path, file), []byte(value), 0)
+}
+
+// attach makes cmd start directly inside the cgroup (clone3 CLONE_INTO_CGROUP),
+// so not even its first instructions run outside of it
+func (cg *cgroup) attach(cmd *exec.Cmd) error {
+	dir, err := os.Open(cg.path)
+	if err != nil {
+		return err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+
+	// The descriptor must stay open until Start returned
+	cg.dir = dir
+	return nil
+}
+
+// started releases what attach kept open for Start
+func (cg *cgroup) started() {
+	if cg.dir != nil {
+		cg.dir.Close()
+		cg.dir = nil
+	}
+}
+
+// kill sends SIGKILL to every process of the cgroup
+func (cg *cgroup) kill() error {
+	return cg.write("cgroup.kill", "1")
+}
+
+// remove kills what is left in the cgroup and deletes it
+func (cg *cgroup) remove() error {
+	cg.started()
+	cg.kill()
+
+	deadline := time.Now().Add(cgroupRemoveTimeout)
+	for {
+		err := os.Remove(cg.path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		// EBUSY until the killed processes are gone
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			return fmt.Errorf("removing cgroup %s: %w", cg.path, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// oomKills returns the oom_kill counter of memory.events, 0 without the memory controller
+func (cg *cgroup) oomKills() int {
+	values, err := readCgroupKeyValues(filepath.Join(cg.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(values["oom_kill"])
+	return n
+}
+
+func (cg *cgroup) stats() (CgroupStats, error) {
+	stats := CgroupStats{Path: cg.path, OOMKills: cg.oomKills()}
+
+	procs, err := os.ReadFile(filepath.Join(cg.path, "cgroup.procs"))
+	if err != nil {
+		return CgroupStats{}, err
+	}
+	stats.Processes = len(strings.Fields(string(procs)))
+
+	if cpu, err := readCgroupKeyValues(filepath.Join(cg.path, "cpu.stat")); err == nil {
+		stats.CPUUsage = time.Duration(parseUint(cpu["usage_usec"])) * time.Microsecond
+	}
+	if data, err := os.ReadFile(filepath.Join(cg.path, "memory.current")); err == nil {
+		stats.MemoryCurrent = parseUint(string(data))
+	}
+	if data, err := os.ReadFile(filepath.Join(cg.path, "memory.peak")); err == nil {
+		stats.MemoryPeak = parseUint(string(data))
+	}
+	return stats, nil
+}
+
+// readCgroupKeyValues parses "key value" lines like cpu.stat and memory.events
+func readCgroupKeyValues(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(line, " "); ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
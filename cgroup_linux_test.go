@@ -0,0 +1,182 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// requireCgroups skips the test when this process can't create cgroups
+func requireCgroups(t *testing.T) {
+	t.Helper()
+	cg, err := newCgroup(&CgroupConfig{Required: true}, func(error) {})
+	if err != nil {
+		t.Skipf("cgroup v2 not delegated: %v", err)
+	}
+	cg.remove()
+}
+
+// processGone reports whether pid exited, zombies included
+func processGone(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	s := string(stat)
+	return strings.HasPrefix(s[strings.LastIndexByte(s, ')')+1:], " Z")
+}
+
+func TestCgroup_KillsEscapedDescendants(t *testing.T) {
+	requireCgroups(t)
+
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		// setsid leaves the process group, not the cgroup
+		RunArguments: func() []string { return []string{"-c", "setsid sleep 30 & sleep 30"} },
+		ExitChan:     make(chan bool),
+		Cgroup:       &CgroupConfig{Required: true},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	path := gr.CgroupPath()
+	if path == "" {
+		t.Fatal("CgroupPath() should be set while running")
+	}
+	own, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", gr.GetPID()))
+	if err != nil || !strings.Contains(string(own), "/"+filepath.Base(path)+"\n") {
+		t.Errorf("Program is not in %s: %s", path, own)
+	}
+
+	var pids []int
+	deadline := time.Now().Add(2 * time.Second)
+	for len(pids) < 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		data, _ := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+		pids = pids[:0]
+		for _, field := range strings.Fields(string(data)) {
+			pid, _ := strconv.Atoi(field)
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) < 3 {
+		t.Fatalf("Expected sh and both sleeps in the cgroup, got %v", pids)
+	}
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+
+	for _, pid := range pids {
+		for deadline := time.Now().Add(2 * time.Second); !processGone(pid) && time.Now().Before(deadline); {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if !processGone(pid) {
+			t.Errorf("Process %d of the cgroup survived the stop", pid)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Cgroup %s should have been removed, got %v", path, err)
+	}
+}
+
+func TestCgroup_ForcedStopAndStats(t *testing.T) {
+	requireCgroups(t)
+
+	execPath := buildTestProgram(t, "stubborn_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		StopTimeout:     200 * time.Millisecond,
+		Cgroup:          &CgroupConfig{Required: true},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	stats, err := gr.CgroupStats()
+	if err != nil {
+		t.Fatalf("CgroupStats() failed: %v", err)
+	}
+	if stats.Path != gr.CgroupPath() || stats.Processes != 1 || stats.CPUUsage <= 0 {
+		t.Errorf("Unexpected cgroup stats: %+v", stats)
+	}
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if exit := gr.LastExit(); exit == nil || exit.Signal != syscall.SIGKILL {
+		t.Errorf("Expected the cgroup to be killed, got %v", exit)
+	}
+}
+
+func TestCgroup_MemoryMaxOOM(t *testing.T) {
+	requireCgroups(t)
+
+	exits := make(chan *ExitInfo, 1)
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", `x=$(head -c 200000000 /dev/zero | tr '\0' x)`} },
+		ExitChan:        make(chan bool),
+		Cgroup:          &CgroupConfig{MemoryMax: 32 << 20, Required: true},
+		OnEvent: func(ev Event) {
+			if ev.Type == EventExited {
+				exits <- ev.Exit
+			}
+		},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Skipf("memory controller not available: %v", err)
+	}
+
+	select {
+	case exit := <-exits:
+		if exit.Limit != LimitMemoryMax {
+			t.Errorf("Expected an OOM kill, got %+v", exit)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Program was never OOM killed")
+	}
+}
+
+func TestCgroup_Fallback(t *testing.T) {
+	config := &Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"5"} },
+		ExitChan:        make(chan bool),
+		Cgroup:          &CgroupConfig{Parent: t.TempDir()},
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() should fall back without cgroup: %v", err)
+	}
+	if !gr.IsRunning() || gr.CgroupPath() != "" {
+		t.Error("Program should run without cgroup")
+	}
+	if out := gr.getOutput(); !strings.Contains(out, "Warning: running without cgroup") {
+		t.Errorf("Expected a fallback warning, got %q", out)
+	}
+	gr.StopProgram()
+
+	config.Cgroup.Required = true
+	if err := New(config).RunProgram(); err == nil || !strings.Contains(err.Error(), "not a cgroup v2 directory") {
+		t.Errorf("Expected RunProgram() to fail with Required, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// newCgroup falls back to running without cgroup, they are Linux only
+func newCgroup(c *CgroupConfig, warn func(error)) (*cgroup, error) {
+	err := errors.New("cgroups are only supported on Linux")
+	if c.Required {
+		return nil, err
+	}
+	warn(fmt.Errorf("running without cgroup: %w", err))
+	return nil, nil
+}
+
+func (cg *cgroup) attach(cmd *exec.Cmd) error { return errors.ErrUnsupported }
+func (cg *cgroup) started()                   {}
+func (cg *cgroup) kill() error                { return errors.ErrUnsupported }
+func (cg *cgroup) remove() error              { return nil }
+func (cg *cgroup) oomKills() int              { return 0 }
+
+func (cg *cgroup) stats() (CgroupStats, error) {
+	return CgroupStats{}, errors.ErrUnsupported
+}
@@ -59,18 +59,27 @@ func killAllUnix(executableName string) error {
 		}
 
 		// Find the process and kill it
-		process, err := os.FindProcess(pid)
+		process, err := openProcessHandle(pid)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("failed to find process %d: %v", pid, err))
+			if err != os.ErrProcessDone {
+				errors = append(errors, fmt.Sprintf("failed to find process %d: %v", pid, err))
+			}
+			continue
+		}
+
+		// pgrep matched the PID before the handle was opened, it may belong to another process by now
+		if cmdline := processCmdline(pid); cmdline != "" && !strings.Contains(cmdline, executableName) {
+			process.close()
 			continue
 		}
 
-		// Try graceful kill first (SIGTERM), then force kill if needed
-		if err := process.Signal(os.Interrupt); err != nil {
-			if err := process.Kill(); err != nil {
+		// Try graceful kill first (SIGINT), then force kill if needed
+		if err := process.signal(os.Interrupt); err != nil && err != os.ErrProcessDone {
+			if err := process.signal(os.Kill); err != nil && err != os.ErrProcessDone {
 				errors = append(errors, fmt.Sprintf("failed to kill process %d: %v", pid, err))
 			}
 		}
+		process.close()
 	}
 
 	if len(errors) > 0 {
@@ -83,8 +92,11 @@ func killAllUnix(executableName string) error {
 // StopProgramAndCleanup stops the current program and optionally kills all instances
 // of the same executable name
 func (h *GoRun) StopProgramAndCleanup(killAll bool) error {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
+	h.generation++
 	return h.stopProgramAndCleanupUnsafe(killAll)
 }
 
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadEnvFiles reads every env file in order and returns their KEY=VALUE entries
+func loadEnvFiles(paths []string) ([]string, error) {
+	var env []string
+	for _, path := range paths {
+		entries, err := loadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, entries...)
+	}
+	return env, nil
+}
+
+// loadEnvFile parses KEY=VALUE lines. Blank lines, # comments and an "export "
+// prefix are accepted; values may be wrapped in single or double quotes.
+func loadEnvFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var env []string
+	line := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		key, value, found := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 {
+			switch value[0] {
+			case '"':
+				if value[len(value)-1] != '"' {
+					return nil, fmt.Errorf("%s:%d: unterminated \" quote", path, line)
+				}
+				if value, err = strconv.Unquote(value); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+				}
+			case '\'':
+				if value[len(value)-1] != '\'' {
+					return nil, fmt.Errorf("%s:%d: unterminated ' quote", path, line)
+				}
+				value = value[1 : len(value)-1]
+			}
+		}
+
+		env = append(env, key+"="+value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return env, nil
+}
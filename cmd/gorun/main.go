@@ -0,0 +1,316 @@
+// Command gorun runs a program, or every program of a process file, under the
+// gorun stop/restart/cleanup semantics and exits with the program's exit code.
+//
+//	gorun [flags] -- command [args...]
+//	gorun [-env-file file] [-log file] -f Procfile
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cdvelop/gorun"
+)
+
+// Exit codes used when the program itself could not provide one
+const (
+	exitError = 1
+	exitUsage = 2
+)
+
+type options struct {
+	processFile  string
+	dir          string
+	envFiles     stringList
+	stopSignal   string
+	stopTimeout  time.Duration
+	restart      string
+	restartDelay time.Duration
+	maxAttempts  int
+	readyTCP     string
+	readyHTTP    string
+	readyTimeout time.Duration
+	logFile      string
+	killAll      bool
+}
+
+// commandFlags only configure a single command: with -f every process sets them in the file
+var commandFlags = []string{
+	"dir", "stop-signal", "stop-timeout", "restart", "restart-delay", "max-restart-attempts",
+	"ready-tcp", "ready-http", "ready-timeout", "kill-all",
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, signals))
+}
+
+// run is main without the process globals so it can be tested
+func run(args []string, stdout, stderr io.Writer, signals <-chan os.Signal) int {
+	var opts options
+
+	flags := flag.NewFlagSet("gorun", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gorun [flags] -- command [args...]")
+		fmt.Fprintln(stderr, "       gorun [-env-file file] [-log file] -f Procfile|processes.json")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&opts.processFile, "f", "", "run every program of a Procfile or JSON process file (only -env-file and -log apply to all)")
+	flags.StringVar(&opts.dir, "dir", "", "working directory of the program")
+	flags.Var(&opts.envFiles, "env-file", "load KEY=VALUE lines into the program environment (repeatable)")
+	flags.StringVar(&opts.stopSignal, "stop-signal", "SIGTERM", "signal sent for a graceful stop")
+	flags.DurationVar(&opts.stopTimeout, "stop-timeout", 3*time.Second, "time allowed for a graceful stop before killing")
+	flags.StringVar(&opts.restart, "restart", "never", "restart policy: never, on-failure or always")
+	flags.DurationVar(&opts.restartDelay, "restart-delay", time.Second, "wait before an automatic restart, doubled after each failed attempt")
+	flags.IntVar(&opts.maxAttempts, "max-restart-attempts", 0, "give up after this many automatic restarts in a row failing to start (0: no limit)")
+	flags.StringVar(&opts.readyTCP, "ready-tcp", "", "readiness probe: address that must accept TCP connections")
+	flags.StringVar(&opts.readyHTTP, "ready-http", "", "readiness probe: URL that must answer 2xx or 3xx")
+	flags.DurationVar(&opts.readyTimeout, "ready-timeout", 10*time.Second, "max time to wait for the readiness probe")
+	flags.StringVar(&opts.logFile, "log", "", "also append the program output to this file")
+	flags.BoolVar(&opts.killAll, "kill-all", false, "on stop, kill every process with the same executable name")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	command := flags.Args()
+	if (opts.processFile == "") == (len(command) == 0) {
+		flags.Usage()
+		return exitUsage
+	}
+	if opts.processFile != "" {
+		var ignored []string
+		flags.Visit(func(f *flag.Flag) {
+			if slices.Contains(commandFlags, f.Name) {
+				ignored = append(ignored, "-"+f.Name)
+			}
+		})
+		if len(ignored) > 0 {
+			fmt.Fprintf(stderr, "gorun: %s can't be used with -f: set it for each process of a JSON process file\n", strings.Join(ignored, ", "))
+			return exitUsage
+		}
+	}
+
+	env, err := loadEnvFiles(opts.envFiles)
+	if err != nil {
+		fmt.Fprintf(stderr, "gorun: %v\n", err)
+		return exitUsage
+	}
+
+	output := stdout
+	if opts.logFile != "" {
+		file, err := os.OpenFile(opts.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(stderr, "gorun: %v\n", err)
+			return exitError
+		}
+		defer file.Close()
+		output = io.MultiWriter(stdout, file)
+	}
+
+	if opts.processFile != "" {
+		return runProcessFile(opts, env, output, stderr, signals)
+	}
+
+	config, err := opts.config(command, env)
+	if err != nil {
+		fmt.Fprintf(stderr, "gorun: %v\n", err)
+		return exitUsage
+	}
+	return runCommand(config, output, stderr, signals)
+}
+
+// config builds the Config of a single command from the flags
+func (o *options) config(command []string, env []string) (*gorun.Config, error) {
+	c := &gorun.Config{
+		ExecProgramPath: command[0],
+		RunArguments:    func() []string { return command[1:] },
+		WorkingDir:      o.dir,
+		Env:             env,
+		StopTimeout:     o.stopTimeout,
+		KillAllOnStop:   o.killAll,
+
+		RestartDelay:       o.restartDelay,
+		MaxRestartAttempts: o.maxAttempts,
+	}
+
+	var err error
+	if c.StopSignal, err = gorun.ParseSignal(o.stopSignal); err != nil {
+		return nil, fmt.Errorf("-stop-signal: %v", err)
+	}
+	if c.RestartPolicy, err = gorun.ParseRestartPolicy(o.restart); err != nil {
+		return nil, fmt.Errorf("-restart: %v", err)
+	}
+
+	switch {
+	case o.readyTCP != "" && o.readyHTTP != "":
+		return nil, fmt.Errorf("-ready-tcp and -ready-http are exclusive")
+	case o.readyTCP != "":
+		c.ReadinessProbe = gorun.TCPProbe(o.readyTCP)
+	case o.readyHTTP != "":
+		c.ReadinessProbe = gorun.HTTPProbe(o.readyHTTP)
+	}
+	c.ReadinessTimeout = o.readyTimeout
+
+	return c, nil
+}
+
+// runCommand runs one program until it exits for good or a signal stops it
+func runCommand(c *gorun.Config, output, stderr io.Writer, signals <-chan os.Signal) int {
+	exits := make(chan *gorun.ExitInfo, 16)
+	gaveUp := make(chan error, 1)
+	c.Logger = outputLogger(output, "")
+	c.OnEvent = func(ev gorun.Event) {
+		switch ev.Type {
+		case gorun.EventExited:
+			exits <- ev.Exit
+		case gorun.EventGaveUp:
+			gaveUp <- ev.Err
+		}
+	}
+
+	r := gorun.New(c)
+	if err := r.RunProgram(); err != nil {
+		fmt.Fprintf(stderr, "gorun: %v\n", err)
+		return exitError
+	}
+
+	for {
+		select {
+		case <-signals:
+			if err := r.StopProgram(); err != nil {
+				fmt.Fprintf(stderr, "gorun: %v\n", err)
+			}
+			// The exit event is delivered before a graceful StopProgram returns,
+			// after a forced kill it may still be on its way
+			select {
+			case exit := <-exits:
+				return exitCode(exit)
+			case <-time.After(time.Second):
+				return exitCode(r.LastExit())
+			}
+		case exit := <-exits:
+			// Only a signal stops the program here: a requested exit is a restart
+			// rolled back when not ready, the RestartPolicy goes on
+			if !exit.Requested && !c.RestartPolicy.ShouldRestart(exit.Code) {
+				return exitCode(exit)
+			}
+		case err := <-gaveUp:
+			fmt.Fprintf(stderr, "gorun: not restarted again: %v\n", err)
+			return exitError
+		}
+	}
+}
+
+// runProcessFile runs every program of a process file until all of them exited
+// for good or a signal stops them. The exit code is the first failure, if any.
+func runProcessFile(opts options, env []string, output, stderr io.Writer, signals <-chan os.Signal) int {
+	defs, err := gorun.LoadProcessFile(opts.processFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "gorun: %v\n", err)
+		return exitUsage
+	}
+
+	m := gorun.NewManager(&gorun.ManagerConfig{Logger: outputLogger(output, "\n")})
+
+	type finalExit struct {
+		name   string
+		exit   *gorun.ExitInfo
+		gaveUp error // Why the RestartPolicy stopped restarting it, exit is the last one then
+	}
+	finals := make(chan finalExit, 16*len(defs))
+
+	for _, def := range defs {
+		name, policy := def.Name, def.Config.RestartPolicy
+		def.Config.Env = append(append([]string(nil), env...), def.Config.Env...)
+		// Requested exits are restarts, the dependants of a restarted program or a
+		// restart rolled back when not ready: only a signal stops the programs here
+		def.Config.OnEvent = func(ev gorun.Event) {
+			switch {
+			case ev.Type == gorun.EventExited && !ev.Exit.Requested && !policy.ShouldRestart(ev.Exit.Code):
+				finals <- finalExit{name: name, exit: ev.Exit}
+			case ev.Type == gorun.EventGaveUp:
+				finals <- finalExit{name: name, exit: ev.Exit, gaveUp: ev.Err}
+			}
+		}
+		if _, err := m.Add(def.Name, def.Config); err != nil {
+			fmt.Fprintf(stderr, "gorun: %s:%d: %v\n", opts.processFile, def.Line, err)
+			return exitUsage
+		}
+	}
+
+	if err := m.StartAll(); err != nil {
+		fmt.Fprintf(stderr, "gorun: %v\n", err)
+		return exitError
+	}
+
+	code := 0
+	record := func(f finalExit) {
+		if f.gaveUp != nil {
+			fmt.Fprintf(stderr, "gorun: %s: not restarted again: %v\n", f.name, f.gaveUp)
+			if code == 0 {
+				code = exitError
+			}
+			return
+		}
+		if c := exitCode(f.exit); c != 0 && code == 0 {
+			fmt.Fprintf(stderr, "gorun: %s: %v\n", f.name, f.exit)
+			code = c
+		}
+	}
+
+	for pending := len(defs); pending > 0; {
+		select {
+		case <-signals:
+			if err := m.StopAll(); err != nil {
+				fmt.Fprintf(stderr, "gorun: %v\n", err)
+			}
+			return code
+		case f := <-finals:
+			record(f)
+			pending--
+		}
+	}
+	return code
+}
+
+// outputLogger writes the program output as is, adding suffix to every call
+func outputLogger(w io.Writer, suffix string) func(message ...any) {
+	var mu sync.Mutex
+	return func(message ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, message...)
+		fmt.Fprint(w, suffix)
+	}
+}
+
+// exitCode converts an ExitInfo to a shell exit code: 128+signal when signaled
+func exitCode(exit *gorun.ExitInfo) int {
+	if exit == nil {
+		return 0
+	}
+	if sig, ok := exit.Signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	if exit.Code < 0 {
+		return exitError
+	}
+	return exit.Code
+}
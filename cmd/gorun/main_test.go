@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// buildTestProgram builds one of the root package testdata programs
+func buildTestProgram(t *testing.T, programName string) string {
+	t.Helper()
+
+	execPath := filepath.Join(t.TempDir(), programName)
+	cmd := exec.Command("go", "build", "-o", execPath, filepath.Join("..", "..", "testdata", programName+".go"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build test program %s: %v\n%s", programName, err, out)
+	}
+	return execPath
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	execPath := buildTestProgram(t, "error_program")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--", execPath}, &stdout, &stderr, nil)
+
+	if code != 1 {
+		t.Errorf("Expected the program exit code 1, got %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "ERROR_PROGRAM_STARTED") {
+		t.Errorf("Expected program output, got: %s", stdout.String())
+	}
+}
+
+func TestRun_EnvFileAndLog(t *testing.T) {
+	execPath := buildTestProgram(t, "args_program")
+	dir := t.TempDir()
+
+	envFile := filepath.Join(dir, "dev.env")
+	os.WriteFile(envFile, []byte("# dev\nexport HTTP_PORT=\"1234\"\n"), 0644)
+	logFile := filepath.Join(dir, "out.log")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-env-file", envFile, "-log", logFile, "--", execPath, "a", "b"}, &stdout, &stderr, nil)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr.String())
+	}
+
+	logged, _ := os.ReadFile(logFile)
+	for _, output := range []string{stdout.String(), string(logged)} {
+		if !strings.Contains(output, "ARGS:a,b") || !strings.Contains(output, "HTTP_PORT:1234") {
+			t.Errorf("Expected arguments and env in output, got: %s", output)
+		}
+	}
+}
+
+func TestRun_SignalStopsProgram(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+
+	signals := make(chan os.Signal, 1)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		signals <- os.Interrupt
+	}()
+
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	code := run([]string{"--", execPath}, &stdout, &stderr, signals)
+
+	if time.Since(start) > 3*time.Second {
+		t.Error("The signal should have stopped the program early")
+	}
+	// long_program does not handle SIGTERM, so it dies from it
+	if code != 128+int(syscall.SIGTERM) {
+		t.Errorf("Expected exit code %d, got %d", 128+int(syscall.SIGTERM), code)
+	}
+}
+
+func TestRun_ProcessFile(t *testing.T) {
+	okPath := buildTestProgram(t, "args_program")
+	failPath := buildTestProgram(t, "error_program")
+
+	procfile := filepath.Join(t.TempDir(), "Procfile")
+	os.WriteFile(procfile, []byte("ok: "+okPath+" x\nfail: "+failPath+"\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-f", procfile}, &stdout, &stderr, nil)
+
+	if code != 1 {
+		t.Errorf("Expected the failing program exit code 1, got %d (stderr: %s)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "ARGS:x") || !strings.Contains(stdout.String(), "ERROR_PROGRAM_STARTED") {
+		t.Errorf("Expected output of both programs, got: %s", stdout.String())
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-f", "Procfile", "--", "ls"},
+		{"-restart", "sometimes", "--", "ls"},
+		{"-stop-signal", "SIGFOO", "--", "ls"},
+		{"-unknown-flag"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr, nil); code != exitUsage {
+			t.Errorf("run(%q) = %d, want %d", args, code, exitUsage)
+		}
+	}
+}
+
+func TestRun_ProcessFileRejectsCommandFlags(t *testing.T) {
+	procfile := filepath.Join(t.TempDir(), "Procfile")
+	os.WriteFile(procfile, []byte("ok: true\n"), 0644)
+
+	for _, flag := range [][]string{
+		{"-dir", "/tmp"},
+		{"-stop-signal", "SIGINT"},
+		{"-stop-timeout", "5s"},
+		{"-restart", "always"},
+		{"-restart-delay", "2s"},
+		{"-max-restart-attempts", "3"},
+		{"-ready-tcp", "127.0.0.1:8080"},
+		{"-ready-http", "http://127.0.0.1:8080/health"},
+		{"-ready-timeout", "5s"},
+		{"-kill-all"},
+	} {
+		var stdout, stderr bytes.Buffer
+		args := append(append([]string(nil), flag...), "-f", procfile)
+		if code := run(args, &stdout, &stderr, nil); code != exitUsage {
+			t.Errorf("run(%q) = %d, want %d", args, code, exitUsage)
+		}
+		if !strings.Contains(stderr.String(), flag[0]+" can't be used with -f") {
+			t.Errorf("run(%q): expected the rejected flag in the error, got %q", args, stderr.String())
+		}
+		if stdout.Len() > 0 {
+			t.Errorf("run(%q): nothing should run, got %q", args, stdout.String())
+		}
+	}
+
+	// The flags that apply to every process are accepted
+	envFile := filepath.Join(t.TempDir(), "dev.env")
+	os.WriteFile(envFile, []byte("A=1\n"), 0644)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-env-file", envFile, "-f", procfile}, &stdout, &stderr, nil); code != 0 {
+		t.Errorf("Expected -env-file to be accepted with -f, got %d (stderr: %s)", code, stderr.String())
+	}
+}
+
+// runWithin runs the CLI with args, failing the test if it doesn't return within timeout
+func runWithin(t *testing.T, timeout time.Duration, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	done := make(chan int, 1)
+	go func() { done <- run(args, &stdout, &stderr, nil) }()
+	select {
+	case code := <-done:
+		return code, stderr.String()
+	case <-time.After(timeout):
+		t.Fatalf("run(%q) did not return", args)
+		return 0, ""
+	}
+}
+
+// selfRemovingScript writes a script that fails once, its restarts then can't start it
+func selfRemovingScript(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "once.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nrm -f \"$0\"\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_GivesUpRestarting(t *testing.T) {
+	script := selfRemovingScript(t)
+
+	code, stderr := runWithin(t, 5*time.Second,
+		"-restart", "always", "-restart-delay", "20ms", "-max-restart-attempts", "2", "--", script)
+	if code != exitError || !strings.Contains(stderr, "not restarted again") {
+		t.Errorf("Expected exit code %d once restarts gave up, got %d (stderr: %s)", exitError, code, stderr)
+	}
+}
+
+func TestRun_RestartNotReadyKeepsRetrying(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ready at the first start only
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ln.Close()
+	}()
+
+	// Each restart is rolled back by the failed probe before gorun gives up
+	code, stderr := runWithin(t, 10*time.Second,
+		"-restart", "always", "-restart-delay", "20ms", "-max-restart-attempts", "2",
+		"-ready-tcp", ln.Addr().String(), "-ready-timeout", "200ms", "--", "sh", "-c", "sleep 0.3; exit 3")
+	if code != exitError || strings.Count(stderr, "not restarted again") != 1 {
+		t.Errorf("Expected to wait for every restart attempt, then exit code %d, got %d (stderr: %s)", exitError, code, stderr)
+	}
+}
+
+func TestRun_ProcessFileGivesUpRestarting(t *testing.T) {
+	script := selfRemovingScript(t)
+	file := filepath.Join(t.TempDir(), "processes.json")
+	os.WriteFile(file, []byte(`{"processes": [
+		{"name": "once", "command": "`+script+`", "restart": "always", "restart_delay": "20ms", "max_restart_attempts": 2}
+	]}`), 0644)
+
+	code, stderr := runWithin(t, 5*time.Second, "-f", file)
+	if code != exitError || !strings.Contains(stderr, "once: not restarted again") {
+		t.Errorf("Expected exit code %d once restarts gave up, got %d (stderr: %s)", exitError, code, stderr)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("A=1\n\n# comment\nexport B = 'two words'\nC=\"x\\ty\"\nD=\n"), 0644)
+
+	env, err := loadEnvFile(path)
+	if err != nil {
+		t.Fatalf("loadEnvFile() failed: %v", err)
+	}
+	if want := []string{"A=1", "B=two words", "C=x\ty", "D="}; !reflect.DeepEqual(env, want) {
+		t.Errorf("loadEnvFile() = %q, want %q", env, want)
+	}
+
+	os.WriteFile(path, []byte("A=1\nnot a variable\n"), 0644)
+	if _, err := loadEnvFile(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+package gorun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Control API commands, sent as ControlRequest.Command
+const (
+	ControlStatus  = "status"  // Answers Status
+	ControlStart   = "start"   // Starts the program if it is not running
+	ControlStop    = "stop"    // StopProgram
+	ControlRestart = "restart" // RunProgram
+	ControlTail    = "tail"    // Answers the last Lines of output
+	ControlFollow  = "follow"  // Answers the last Lines of output, then streams the new output
+	ControlRaces   = "races"   // Answers Races
+)
+
+// defaultTailLines is used when a tail or follow request doesn't set Lines
+const defaultTailLines = 100
+
+// ControlRequest is one line sent by a control client
+type ControlRequest struct {
+	Command string `json:"command"`
+	Lines   int    `json:"lines,omitempty"` // tail and follow: lines of past output (default: 100, -1 for none)
+}
+
+// ControlResponse is one line sent by the control server. A follow request gets
+// one response with the past output, then one per write of the program.
+type ControlResponse struct {
+	OK     bool         `json:"ok"`
+	Error  string       `json:"error,omitempty"`
+	Status *Status      `json:"status,omitempty"`
+	Output string       `json:"output,omitempty"`
+	Races  []RaceReport `json:"races,omitempty"`
+}
+
+// ControlServer serves the control API of a GoRun on a Unix domain socket.
+// The protocol is JSON lines: one ControlRequest, then one ControlResponse
+// (or a stream of them for follow), repeated until the client disconnects.
+type ControlServer struct {
+	h        *GoRun
+	path     string
+	listener net.Listener
+
+	mutex  sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ServeControl listens on socketPath and serves the control API in the background.
+// The socket is only accessible by the current user. A stale socket file left by
+// a previous run is replaced; one still being served is an error.
+func (h *GoRun) ServeControl(socketPath string) (*ControlServer, error) {
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("gorun control: %s exists and is not a socket", socketPath)
+		}
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("gorun control: %s is already in use", socketPath)
+		}
+		os.Remove(socketPath)
+	}
+
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("gorun control: %v", err)
+	}
+
+	s := &ControlServer{
+		h:        h,
+		path:     socketPath,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// listenPrivate listens on socketPath, a socket no other user can connect to at
+// any time: it is created in a directory only the current user can enter, made
+// private there and only then moved to socketPath
+func listenPrivate(socketPath string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".gorun-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// The file moves, Close removes it from its final path
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, socketPath); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Path returns the socket path
+func (s *ControlServer) Path() string {
+	return s.path
+}
+
+// Close stops listening, disconnects every client and removes the socket file
+func (s *ControlServer) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	os.Remove(s.path)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *ControlServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mutex.Lock()
+			delete(s.conns, conn)
+			s.mutex.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handle answers the requests of one client until it disconnects
+func (s *ControlServer) handle(conn net.Conn) {
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		var req ControlRequest
+		if err := decoder.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				encoder.Encode(ControlResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			}
+			return
+		}
+
+		if req.Command == ControlFollow {
+			s.follow(conn, encoder, req.Lines)
+			return
+		}
+		if err := encoder.Encode(s.answer(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *ControlServer) answer(req ControlRequest) ControlResponse {
+	var err error
+	switch req.Command {
+	case ControlStatus:
+		status := s.h.Status()
+		return ControlResponse{OK: true, Status: &status}
+	case ControlStart:
+		if s.h.IsRunning() {
+			err = errors.New("program is already running")
+		} else {
+			err = s.h.RunProgram()
+		}
+	case ControlStop:
+		err = s.h.StopProgram()
+	case ControlRestart:
+		err = s.h.RunProgram()
+	case ControlTail:
+		return ControlResponse{OK: true, Output: s.h.OutputTail(tailLines(req.Lines))}
+	case ControlRaces:
+		return ControlResponse{OK: true, Races: s.h.Races()}
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+
+	if err != nil {
+		return ControlResponse{Error: err.Error()}
+	}
+	return ControlResponse{OK: true}
+}
+
+// follow streams the program output until the client disconnects
+func (s *ControlServer) follow(conn net.Conn, encoder *json.Encoder, lines int) {
+	tail, output, cancel := s.h.FollowOutput(tailLines(lines))
+	defer cancel()
+
+	if err := encoder.Encode(ControlResponse{OK: true, Output: tail}); err != nil {
+		return
+	}
+
+	// Nothing else is expected from the client: a read returning means it left
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case p := <-output:
+			if err := encoder.Encode(ControlResponse{OK: true, Output: string(p)}); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+func tailLines(lines int) int {
+	if lines == 0 {
+		return defaultTailLines
+	}
+	return lines
+}
+
+// ControlClient talks to a ControlServer. Every call uses its own connection.
+type ControlClient struct {
+	path    string
+	Timeout time.Duration // Max time for a call other than Follow (default: 30s, start and restart wait for readiness)
+}
+
+// NewControlClient returns a client for the control socket at socketPath
+func NewControlClient(socketPath string) *ControlClient {
+	return &ControlClient{path: socketPath}
+}
+
+func (c *ControlClient) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 30 * time.Second
+}
+
+// call sends one request and returns its response, a failed command is an error
+func (c *ControlClient) call(req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", c.path, c.timeout())
+	if err != nil {
+		return nil, fmt.Errorf("gorun control: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout()))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("gorun control: %v", err)
+	}
+	var resp ControlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("gorun control: %v", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("gorun control: %s: %s", req.Command, resp.Error)
+	}
+	return &resp, nil
+}
+
+// Status returns the status of the program
+func (c *ControlClient) Status() (*Status, error) {
+	resp, err := c.call(ControlRequest{Command: ControlStatus})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil {
+		return nil, errors.New("gorun control: status: empty response")
+	}
+	return resp.Status, nil
+}
+
+// Start starts the program, it is an error if it is already running
+func (c *ControlClient) Start() error {
+	_, err := c.call(ControlRequest{Command: ControlStart})
+	return err
+}
+
+// Stop stops the program
+func (c *ControlClient) Stop() error {
+	_, err := c.call(ControlRequest{Command: ControlStop})
+	return err
+}
+
+// Restart restarts the program, or starts it if it is not running
+func (c *ControlClient) Restart() error {
+	_, err := c.call(ControlRequest{Command: ControlRestart})
+	return err
+}
+
+// Tail returns the last lines of the program output
+func (c *ControlClient) Tail(lines int) (string, error) {
+	resp, err := c.call(ControlRequest{Command: ControlTail, Lines: lines})
+	if err != nil {
+		return "", err
+	}
+	return resp.Output, nil
+}
+
+// Races returns the distinct data races reported by the program
+func (c *ControlClient) Races() ([]RaceReport, error) {
+	resp, err := c.call(ControlRequest{Command: ControlRaces})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Races, nil
+}
+
+// Follow writes the last lines of the program output to w, then the new output
+// as it is written, until ctx is done or the server goes away
+func (c *ControlClient) Follow(ctx context.Context, lines int, w io.Writer) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return fmt.Errorf("gorun control: %v", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(ControlRequest{Command: ControlFollow, Lines: lines}); err != nil {
+		return fmt.Errorf("gorun control: %v", err)
+	}
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var resp ControlResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("gorun control: %v", err)
+		}
+		if !resp.OK {
+			return fmt.Errorf("gorun control: follow: %s", resp.Error)
+		}
+		if _, err := io.WriteString(w, resp.Output); err != nil {
+			return err
+		}
+	}
+}
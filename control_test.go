@@ -0,0 +1,238 @@
+package gorun
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncWriter is a strings.Builder safe for a writer and a reader goroutine
+type syncWriter struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sb.Write(p)
+}
+
+func (w *syncWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sb.String()
+}
+
+func startControl(t *testing.T, gr *GoRun) *ControlClient {
+	t.Helper()
+	server, err := gr.ServeControl(filepath.Join(t.TempDir(), "gorun.sock"))
+	if err != nil {
+		t.Fatalf("ServeControl() failed: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return NewControlClient(server.Path())
+}
+
+func TestControl_Commands(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool)})
+	defer gr.StopProgram()
+	client := startControl(t, gr)
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if status.State != StateStopped || status.PID != 0 {
+		t.Errorf("Expected a stopped program, got %+v", status)
+	}
+
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if err := client.Start(); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Second Start() should fail, got %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	status, err = client.Status()
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if status.State != StateRunning || status.PID != gr.GetPID() || status.Uptime <= 0 {
+		t.Errorf("Unexpected status of the running program: %+v", status)
+	}
+
+	tail, err := client.Tail(1)
+	if err != nil {
+		t.Fatalf("Tail() failed: %v", err)
+	}
+	if strings.Count(tail, "\n") != 1 || !strings.HasPrefix(tail, "LONG_TICK_") {
+		t.Errorf("Expected the last tick line, got %q", tail)
+	}
+
+	oldPID := status.PID
+	if err := client.Restart(); err != nil {
+		t.Fatalf("Restart() failed: %v", err)
+	}
+	if pid := gr.GetPID(); pid == 0 || pid == oldPID {
+		t.Errorf("Restart() should start a new process, old=%d new=%d", oldPID, pid)
+	}
+
+	if err := client.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	status, err = client.Status()
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if status.State != StateStopped || status.LastExit == nil || !status.LastExit.Requested {
+		t.Errorf("Expected a stopped program with its last exit, got %+v", status)
+	}
+}
+
+func TestControl_Follow(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool)})
+	defer gr.StopProgram()
+	client := startControl(t, gr)
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncWriter
+	done := make(chan error, 1)
+	go func() { done <- client.Follow(ctx, -1, &out) }()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(out.String(), "LONG_TICK_") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !strings.Contains(out.String(), "LONG_TICK_") {
+		t.Errorf("Follow() did not stream the program output, got %q", out.String())
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Follow() should return context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Follow() did not return after cancel")
+	}
+}
+
+func TestControl_Socket(t *testing.T) {
+	gr := New(&Config{ExecProgramPath: "test"})
+	path := filepath.Join(t.TempDir(), "gorun.sock")
+
+	server, err := gr.ServeControl(path)
+	if err != nil {
+		t.Fatalf("ServeControl() failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Socket file missing: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected socket permissions 0600, got %o", perm)
+	}
+	// Created private in a directory of its own, removed once the socket was moved
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected only the socket file, got %v", entries)
+	}
+
+	if _, err := gr.ServeControl(path); err == nil {
+		t.Error("ServeControl() should fail on a socket already being served")
+	}
+
+	resp, err := NewControlClient(path).call(ControlRequest{Command: "reload"})
+	if err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Expected an unknown command error, got %v %+v", err, resp)
+	}
+
+	server.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Close() should remove the socket file, got %v", err)
+	}
+}
+
+func TestStatus_StartingDuringReadinessProbe(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	config := &Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		ReadinessProbe: func(pid int) error {
+			once.Do(func() { close(probing) })
+			select {
+			case <-release:
+				return nil
+			default:
+				return errors.New("not ready yet")
+			}
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+
+	started := make(chan error, 1)
+	go func() { started <- gr.RunProgram() }()
+	<-probing
+
+	statuses := make(chan Status, 1)
+	go func() { statuses <- gr.Status() }()
+	select {
+	case s := <-statuses:
+		if s.State != StateStarting || s.PID == 0 {
+			t.Errorf("Expected a starting process, got %+v", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Status() blocked while the readiness probe runs")
+	}
+
+	close(release)
+	if err := <-started; err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	if s := gr.Status(); s.State != StateRunning {
+		t.Errorf("Expected running once ready, got %+v", s)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\n", 5, "a\nb\n"},
+		{"a\nb\n", 0, ""},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := lastLines(tt.in, tt.n); got != tt.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
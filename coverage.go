@@ -0,0 +1,194 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CoverageConfig collects the coverage of a program built with -cover (Go 1.20+).
+// Every run writes to its own GOCOVERDIR, merged into Dir once the program has
+// exited. Counters are only written when the program exits through main or
+// os.Exit: it must handle the stop signal to be measured.
+type CoverageConfig struct {
+	Dir       string // Cumulative coverage data of every run, created if needed
+	GoCommand string // Runs "go tool covdata" (default: "go")
+}
+
+// coverageRunsDir is the directory of Dir holding the data of the running instances
+const coverageRunsDir = "runs"
+
+func (c *CoverageConfig) goCommand() string {
+	if c.GoCommand != "" {
+		return c.GoCommand
+	}
+	return "go"
+}
+
+// setupCoverage creates the GOCOVERDIR of a new run and sets it in the environment
+// of cmd. It returns the directory, empty without Config.Coverage.
+func (h *GoRun) setupCoverage(cmd *exec.Cmd) (string, error) {
+	if h.Coverage == nil {
+		return "", nil
+	}
+	if h.Coverage.Dir == "" {
+		return "", errors.New("coverage: Dir is required")
+	}
+	// The program may run in another working directory
+	dir, err := filepath.Abs(h.Coverage.Dir)
+	if err != nil {
+		return "", fmt.Errorf("coverage: %v", err)
+	}
+	runs := filepath.Join(dir, coverageRunsDir)
+	if err := os.MkdirAll(runs, 0755); err != nil {
+		return "", fmt.Errorf("coverage: %v", err)
+	}
+	run, err := os.MkdirTemp(runs, "run-")
+	if err != nil {
+		return "", fmt.Errorf("coverage: %v", err)
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GOCOVERDIR="+run)
+	return run, nil
+}
+
+// addPendingCoverage counts a finished run to merge, before its exit is published:
+// CoverageProfile waits for the merge
+func (h *GoRun) addPendingCoverage() {
+	h.coverageMutex.Lock()
+	defer h.coverageMutex.Unlock()
+	h.coveragePending++
+}
+
+// donePendingCoverage ends the merge of a run counted by addPendingCoverage
+func (h *GoRun) donePendingCoverage() {
+	h.coverageMutex.Lock()
+	defer h.coverageMutex.Unlock()
+	h.coveragePending--
+	h.coverageMerged.Broadcast()
+}
+
+// waitCoverageUnsafe waits until every pending run is merged
+// Should only be called when coverageMutex is already held
+func (h *GoRun) waitCoverageUnsafe() {
+	for h.coveragePending > 0 {
+		h.coverageMerged.Wait()
+	}
+}
+
+// mergeCoverage merges the data of a finished run into Coverage.Dir and removes it
+func (h *GoRun) mergeCoverage(run string) error {
+	defer os.RemoveAll(run)
+
+	h.coverageMutex.Lock()
+	defer h.coverageMutex.Unlock()
+
+	meta, counters := coverageFiles(run)
+	if meta == 0 {
+		// Not built with -cover
+		return nil
+	}
+	if counters == 0 {
+		return errors.New("coverage: the program wrote no counters, it did not exit through main or os.Exit")
+	}
+
+	dir := filepath.Dir(filepath.Dir(run))
+	previous, err := coverageFileNames(dir)
+	if err != nil {
+		return fmt.Errorf("coverage: %v", err)
+	}
+	inputs := run
+	if len(previous) > 0 {
+		inputs = dir + "," + run
+	}
+
+	// covdata can't merge into one of its inputs
+	merged, err := os.MkdirTemp(dir, "merge-")
+	if err != nil {
+		return fmt.Errorf("coverage: %v", err)
+	}
+	defer os.RemoveAll(merged)
+	if out, err := exec.Command(h.Coverage.goCommand(), "tool", "covdata", "merge", "-i="+inputs, "-o="+merged).CombinedOutput(); err != nil {
+		return fmt.Errorf("coverage: covdata merge: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	// The merged data is in place before the previous data goes: a failure never
+	// leaves Dir without the runs merged so far
+	entries, err := os.ReadDir(merged)
+	if err != nil {
+		return fmt.Errorf("coverage: %v", err)
+	}
+	replaced := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(merged, e.Name()), filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("coverage: %v", err)
+		}
+		replaced[e.Name()] = true
+	}
+	for _, name := range previous {
+		if replaced[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("coverage: %v", err)
+		}
+	}
+	return nil
+}
+
+// coverageFiles counts the meta-data and counter files of a GOCOVERDIR
+func coverageFiles(dir string) (meta, counters int) {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		switch {
+		case strings.HasPrefix(e.Name(), "covmeta."):
+			meta++
+		case strings.HasPrefix(e.Name(), "covcounters."):
+			counters++
+		}
+	}
+	return meta, counters
+}
+
+// coverageFileNames returns the names of the coverage data files of dir, its other
+// entries are skipped
+func coverageFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "covmeta.") || strings.HasPrefix(e.Name(), "covcounters.") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// CoverageProfile writes the cumulative coverage of every finished run as a text
+// profile, the format of "go test -coverprofile" read by "go tool cover". Runs
+// still being merged are waited for.
+func (h *GoRun) CoverageProfile(path string) error {
+	if h.Coverage == nil {
+		return errors.New("coverage: no Coverage configured")
+	}
+
+	h.coverageMutex.Lock()
+	defer h.coverageMutex.Unlock()
+	h.waitCoverageUnsafe()
+
+	if meta, _ := coverageFiles(h.Coverage.Dir); meta == 0 {
+		return fmt.Errorf("coverage: no coverage data in %s", h.Coverage.Dir)
+	}
+	if out, err := exec.Command(h.Coverage.goCommand(), "tool", "covdata", "textfmt", "-i="+h.Coverage.Dir, "-o="+path).CombinedOutput(); err != nil {
+		return fmt.Errorf("coverage: covdata textfmt: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
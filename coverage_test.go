@@ -0,0 +1,153 @@
+package gorun
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// buildCoverProgram builds cover_program with -cover, skipping without support
+func buildCoverProgram(t *testing.T) string {
+	t.Helper()
+	execPath := filepath.Join(t.TempDir(), "cover_program")
+	if out, err := exec.Command("go", "build", "-cover", "-o", execPath, filepath.Join("testdata", "cover_program.go")).CombinedOutput(); err != nil {
+		t.Skipf("go build -cover failed: %v\n%s", err, out)
+	}
+	return execPath
+}
+
+// runCoverProgram runs the program until it printed its GOCOVERDIR, then stops it
+func runCoverProgram(t *testing.T, gr *GoRun) string {
+	t.Helper()
+	offset := len(gr.getOutput())
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	var dir string
+	for deadline := time.Now().Add(5 * time.Second); dir == "" && time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		out := gr.getOutput()[offset:]
+		if i := strings.Index(out, "COVER_PROGRAM_GOCOVERDIR "); i >= 0 && strings.Contains(out[i:], "\n") {
+			dir = strings.TrimSpace(strings.SplitN(out[i:], "\n", 2)[0][len("COVER_PROGRAM_GOCOVERDIR "):])
+		}
+	}
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	return dir
+}
+
+// waitCoverage waits until the runs that exited are merged
+func waitCoverage(gr *GoRun) {
+	gr.coverageMutex.Lock()
+	defer gr.coverageMutex.Unlock()
+	gr.waitCoverageUnsafe()
+}
+
+func TestCoverage_MergedAcrossRuns(t *testing.T) {
+	execPath := buildCoverProgram(t)
+	dir := filepath.Join(t.TempDir(), "cover")
+	run := "first"
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{run} },
+		ExitChan:        make(chan bool),
+		Coverage:        &CoverageConfig{Dir: dir},
+	})
+	defer gr.StopProgram()
+
+	first := runCoverProgram(t, gr)
+	if !strings.HasPrefix(first, filepath.Join(dir, coverageRunsDir)) {
+		t.Fatalf("Expected a GOCOVERDIR of its own in %s, got %q", dir, first)
+	}
+	waitCoverage(gr)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("The run directory should be removed once merged, got %v", err)
+	}
+
+	run = "second"
+	if second := runCoverProgram(t, gr); second == first || second == "" {
+		t.Errorf("Expected another GOCOVERDIR, got %q", second)
+	}
+
+	// Waits for the second run to be merged
+	profile := filepath.Join(t.TempDir(), "cover.out")
+	if err := gr.CoverageProfile(profile); err != nil {
+		t.Fatalf("CoverageProfile() failed: %v", err)
+	}
+	if out := gr.getOutput(); strings.Contains(out, "Warning") {
+		t.Errorf("Unexpected warning: %s", out)
+	}
+	if meta, counters := coverageFiles(dir); meta != 1 || counters != 1 {
+		t.Errorf("Expected the runs merged in one meta-data and one counter file, got %d and %d", meta, counters)
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both branches ran once, each in its own run
+	covered := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && strings.Contains(fields[0], "cover_program.go") {
+			covered[fields[0][strings.LastIndexByte(fields[0], ':')+1:]] = fields[2] != "0"
+		}
+	}
+	if !strings.HasPrefix(string(data), "mode: ") || len(covered) == 0 {
+		t.Fatalf("Unexpected profile: %s", data)
+	}
+	for block, ok := range covered {
+		if !ok {
+			t.Errorf("Block %s was not covered by any run:\n%s", block, data)
+		}
+	}
+}
+
+func TestCoverage_KilledWritesNoCounters(t *testing.T) {
+	execPath := buildCoverProgram(t)
+	dir := filepath.Join(t.TempDir(), "cover")
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		StopSignal:      syscall.SIGKILL,
+		Coverage:        &CoverageConfig{Dir: dir},
+	})
+	defer gr.StopProgram()
+
+	runCoverProgram(t, gr)
+	waitCoverage(gr)
+	if out := gr.getOutput(); !strings.Contains(out, "wrote no counters") {
+		t.Errorf("Expected a warning about the missing counters, got %q", out)
+	}
+	if err := gr.CoverageProfile(filepath.Join(t.TempDir(), "cover.out")); err == nil {
+		t.Error("CoverageProfile() should fail without coverage data")
+	}
+}
+
+func TestCoverage_NotCoverBuild(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cover")
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", `echo "GOCOVERDIR=$GOCOVERDIR"`} },
+		ExitChan:        make(chan bool),
+		Coverage:        &CoverageConfig{Dir: dir},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); gr.IsRunning() && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+	}
+	waitCoverage(gr)
+	if out := gr.getOutput(); !strings.Contains(out, "GOCOVERDIR="+dir) || strings.Contains(out, "Warning") {
+		t.Errorf("Expected GOCOVERDIR set and no warning, got %q", out)
+	}
+	if entries, err := os.ReadDir(filepath.Join(dir, coverageRunsDir)); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no run left, got %v, %v", entries, err)
+	}
+}
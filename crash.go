@@ -0,0 +1,282 @@
+package gorun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Crash kinds
+const (
+	CrashPanic      = "panic"
+	CrashFatalError = "fatal error"
+	CrashDataRace   = "data race"
+)
+
+// crashReportLimit bounds the report kept for one crash, in bytes
+const crashReportLimit = 1 << 20
+
+// Crash is a panic, fatal error or race detector report found in the output of a Go program
+type Crash struct {
+	Kind       string      `json:"kind"`    // CrashPanic, CrashFatalError or CrashDataRace
+	Message    string      `json:"message"` // eg: "runtime error: index out of range [3] with length 3"
+	Goroutines []Goroutine `json:"goroutines"`
+	Report     string      `json:"report"` // The block as written by the program
+}
+
+// Location returns the first frame outside the Go runtime, where to look first
+func (c *Crash) Location() *StackFrame {
+	for _, g := range c.Goroutines {
+		for i, f := range g.Frames {
+			if !strings.HasPrefix(f.Function, "runtime.") && !strings.HasPrefix(f.Function, "runtime/") &&
+				!strings.HasPrefix(f.Function, "internal/") {
+				return &g.Frames[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Crash) String() string {
+	s := c.Kind + ": " + c.Message
+	if f := c.Location(); f != nil {
+		s += fmt.Sprintf(" (%s at %s:%d)", f.Function, f.File, f.Line)
+	}
+	return s
+}
+
+// raceAccess matches the sections of a race report, eg: "Previous write at 0x00c000018188 by main goroutine:"
+var raceAccess = regexp.MustCompile(`^(.*) at 0x[0-9a-f]+ by (?:goroutine (\d+)|(main) goroutine):$`)
+
+// raceCreation matches "Goroutine 8 (running) created at:"
+var raceCreation = regexp.MustCompile(`^Goroutine (\d+) \([^)]*\) created at:$`)
+
+// Parts of a panic or fatal error block, ordinary output in any of them but the
+// trace means the program only printed something alike
+const (
+	fatalHeader = iota // The message, up to a blank line
+	fatalBlank         // Blank lines before the goroutines
+	fatalTrace         // The goroutines, up to the exit
+)
+
+// crashDetector passes the output of a process through while looking for crash
+// reports in it. Data races are reported as soon as their block ends, a panic or
+// fatal error once the process is gone: nothing follows it but the goroutines.
+type crashDetector struct {
+	out      io.Writer
+	onRace   func(*Crash)
+	mutex    sync.Mutex
+	partial  []byte          // Last line, not terminated yet
+	kind     string          // Kind of the block being read, if any
+	phase    int             // Part of the panic or fatal error block being read
+	report   strings.Builder // Block being read
+	fatal    *Crash
+	lastRace *Crash
+}
+
+func newCrashDetector(out io.Writer, onRace func(*Crash)) *crashDetector {
+	return &crashDetector{out: out, onRace: onRace}
+}
+
+func (d *crashDetector) Write(p []byte) (int, error) {
+	n, err := d.out.Write(p)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		d.scanLine(strings.TrimSuffix(string(d.partial[:i]), "\r"))
+		d.partial = d.partial[i+1:]
+	}
+	if len(d.partial) > crashReportLimit {
+		d.partial = d.partial[:0]
+	}
+	return n, err
+}
+
+// scanLine follows the blocks of the output, one line at a time
+func (d *crashDetector) scanLine(line string) {
+	switch d.kind {
+	case "":
+		switch {
+		case strings.HasPrefix(line, "panic: "):
+			d.kind = CrashPanic
+		case strings.HasPrefix(line, "fatal error: "):
+			d.kind = CrashFatalError
+		case line == "WARNING: DATA RACE":
+			d.kind = CrashDataRace
+			return
+		default:
+			return
+		}
+		d.phase = fatalHeader
+	case CrashDataRace:
+		if strings.HasPrefix(line, "==================") {
+			d.endRace()
+			return
+		}
+	case CrashPanic, CrashFatalError:
+		if !d.followsFatal(line) {
+			// Not a crash: the line is ordinary output, maybe the start of a block
+			d.kind = ""
+			d.report.Reset()
+			d.scanLine(line)
+			return
+		}
+	}
+	if d.report.Len()+len(line) < crashReportLimit {
+		d.report.WriteString(line)
+		d.report.WriteByte('\n')
+	}
+}
+
+// followsFatal reports whether line belongs to the panic or fatal error block being
+// read, moving to its next part
+func (d *crashDetector) followsFatal(line string) bool {
+	switch d.phase {
+	case fatalHeader:
+		switch {
+		case line == "":
+			d.phase = fatalBlank
+		case goroutineHeader.MatchString(line):
+			d.phase = fatalTrace
+		case strings.HasPrefix(line, "\t"), strings.HasPrefix(line, "["),
+			strings.HasPrefix(line, "panic: "), strings.HasPrefix(line, "fatal error: "):
+			// A multi-line message, "[signal SIGSEGV: ...]" or a nested panic
+		default:
+			return false
+		}
+	case fatalBlank:
+		switch {
+		case line == "":
+		case goroutineHeader.MatchString(line), strings.HasPrefix(line, "goroutine "),
+			line == "runtime stack:", strings.HasPrefix(line, "["):
+			d.phase = fatalTrace
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// endRace reports the race block read so far
+func (d *crashDetector) endRace() {
+	crash := parseRaceReport(d.report.String())
+	d.kind = ""
+	d.report.Reset()
+	d.lastRace = crash
+	if d.onRace != nil {
+		d.onRace(crash)
+	}
+}
+
+// finish is called once the output is closed. It returns the panic or fatal error
+// of the process, or its last data race, nil without any.
+func (d *crashDetector) finish() (fatal, lastRace *Crash) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.partial) > 0 {
+		d.scanLine(string(d.partial))
+		d.partial = nil
+	}
+	switch d.kind {
+	case CrashDataRace:
+		d.endRace()
+	case CrashPanic, CrashFatalError:
+		d.fatal = parseFatalReport(d.kind, d.report.String())
+		d.kind = ""
+		d.report.Reset()
+	}
+	return d.fatal, d.lastRace
+}
+
+// parseFatalReport parses a "panic: ..." or "fatal error: ..." block up to the exit
+func parseFatalReport(kind, report string) *Crash {
+	crash := &Crash{Kind: kind, Report: report, Goroutines: parseGoroutines(report)}
+
+	// The message runs up to the first blank line or goroutine
+	header, _, _ := strings.Cut(report, "\n\n")
+	var message []string
+	for _, line := range strings.Split(header, "\n") {
+		if goroutineHeader.MatchString(line) {
+			break
+		}
+		message = append(message, line)
+	}
+	crash.Message = strings.TrimSpace(strings.TrimPrefix(strings.Join(message, "\n"), kind+": "))
+	return crash
+}
+
+// parseRaceReport parses the block between the "WARNING: DATA RACE" line and the closing separator
+func parseRaceReport(report string) *Crash {
+	crash := &Crash{Kind: CrashDataRace, Report: report}
+
+	var accesses []string
+	var current *Goroutine
+	var frame *StackFrame
+	created := map[int]StackFrame{}
+	creator := -1
+	for _, line := range strings.Split(report, "\n") {
+		switch {
+		case line == "":
+			current, frame, creator = nil, nil, -1
+		case strings.HasPrefix(line, "      "):
+			// Location of the previous function line
+			if frame != nil {
+				frame.File, frame.Line = parseFrameLocation(line)
+				if creator >= 0 {
+					created[creator] = *frame
+					creator = -1
+				}
+				frame = nil
+			}
+		case strings.HasPrefix(line, "  "):
+			if current == nil && creator < 0 {
+				continue
+			}
+			function := strings.TrimSpace(line)
+			if i := strings.LastIndexByte(function, '('); i > 0 {
+				function = function[:i]
+			}
+			if current != nil {
+				current.Frames = append(current.Frames, StackFrame{Function: function})
+				frame = &current.Frames[len(current.Frames)-1]
+			} else {
+				// The creation stack: only the frame that started the goroutine is kept
+				frame = &StackFrame{Function: function}
+			}
+		default:
+			if m := raceAccess.FindStringSubmatch(line); m != nil {
+				id := 1
+				who := "main goroutine"
+				if m[3] == "" {
+					id, _ = strconv.Atoi(m[2])
+					who = "goroutine " + m[2]
+				}
+				crash.Goroutines = append(crash.Goroutines, Goroutine{ID: id, State: m[1]})
+				current = &crash.Goroutines[len(crash.Goroutines)-1]
+				accesses = append(accesses, strings.ToLower(m[1])+" by "+who)
+			} else if m := raceCreation.FindStringSubmatch(line); m != nil {
+				creator, _ = strconv.Atoi(m[1])
+				current = nil
+			}
+		}
+	}
+
+	for i := range crash.Goroutines {
+		if f, ok := created[crash.Goroutines[i].ID]; ok {
+			crash.Goroutines[i].CreatedBy = &f
+		}
+	}
+	crash.Message = strings.Join(accesses, ", ")
+	return crash
+}
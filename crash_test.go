@@ -0,0 +1,246 @@
+package gorun
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPanicOutput = `serving
+panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.lookup(...)
+	/src/app/main.go:7
+main.main()
+	/src/app/main.go:12 +0x1d
+`
+
+const testRaceOutput = `==================
+WARNING: DATA RACE
+Read at 0x00c000018188 by goroutine 8:
+  main.main.func1()
+      /src/app/main.go:10 +0x2e
+
+Previous write at 0x00c000018188 by main goroutine:
+  main.main()
+      /src/app/main.go:11 +0xc4
+
+Goroutine 8 (running) created at:
+  main.main()
+      /src/app/main.go:10 +0xa4
+==================
+`
+
+func TestCrashDetector_Panic(t *testing.T) {
+	var out bytes.Buffer
+	d := newCrashDetector(&out, nil)
+	// Lines split across writes
+	for _, chunk := range []string{testPanicOutput[:20], testPanicOutput[20:90], testPanicOutput[90:]} {
+		d.Write([]byte(chunk))
+	}
+	if out.String() != testPanicOutput {
+		t.Errorf("Output should pass through unchanged, got %q", out.String())
+	}
+
+	fatal, race := d.finish()
+	if fatal == nil || race != nil {
+		t.Fatalf("Expected a panic only, got %v and %v", fatal, race)
+	}
+	if fatal.Kind != CrashPanic || fatal.Message != "runtime error: index out of range [3] with length 3" {
+		t.Errorf("Unexpected crash: %+v", fatal)
+	}
+	if len(fatal.Goroutines) != 1 || len(fatal.Goroutines[0].Frames) != 2 {
+		t.Fatalf("Unexpected goroutines: %+v", fatal.Goroutines)
+	}
+	if f := fatal.Location(); f == nil || *f != (StackFrame{Function: "main.lookup", File: "/src/app/main.go", Line: 7}) {
+		t.Errorf("Unexpected location: %+v", f)
+	}
+	if !strings.HasPrefix(fatal.Report, "panic: ") || strings.Contains(fatal.Report, "serving") {
+		t.Errorf("Report should start at the panic, got %q", fatal.Report)
+	}
+}
+
+func TestCrashDetector_FatalError(t *testing.T) {
+	d := newCrashDetector(&bytes.Buffer{}, nil)
+	d.Write([]byte("fatal error: all goroutines are asleep - deadlock!\n\ngoroutine 1 [chan receive]:\nmain.main()\n\t/src/app/main.go:5 +0x1d\n"))
+
+	fatal, _ := d.finish()
+	if fatal == nil || fatal.Kind != CrashFatalError || fatal.Message != "all goroutines are asleep - deadlock!" {
+		t.Fatalf("Unexpected crash: %+v", fatal)
+	}
+	if len(fatal.Goroutines) != 1 || fatal.Goroutines[0].State != "chan receive" {
+		t.Errorf("Unexpected goroutines: %+v", fatal.Goroutines)
+	}
+}
+
+func TestCrashDetector_PanicTextWithoutTrace(t *testing.T) {
+	for _, output := range []string{
+		"panic: not really\nserving\n",
+		"fatal error: logged by the program\n\nstill serving\n",
+		"panic: the message\n\tcontinued\n\nnext line\n",
+	} {
+		d := newCrashDetector(&bytes.Buffer{}, nil)
+		d.Write([]byte(output))
+		if fatal, _ := d.finish(); fatal != nil {
+			t.Errorf("%q: ordinary output after the message should end the block, got %+v", output, fatal)
+		}
+	}
+
+	// Ordinary output may be followed by a real panic
+	d := newCrashDetector(&bytes.Buffer{}, nil)
+	d.Write([]byte("panic: not really\n" + testPanicOutput))
+	if fatal, _ := d.finish(); fatal == nil || !strings.HasPrefix(fatal.Report, "panic: runtime error") {
+		t.Errorf("Expected the real panic, got %+v", fatal)
+	}
+
+	d = newCrashDetector(&bytes.Buffer{}, nil)
+	d.Write([]byte("panic: first [recovered]\n\tpanic: second\n[signal SIGSEGV: segmentation violation]\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/app/main.go:5 +0x1d\n"))
+	if fatal, _ := d.finish(); fatal == nil || len(fatal.Goroutines) != 1 {
+		t.Errorf("Expected a nested panic with its goroutine, got %+v", fatal)
+	}
+}
+
+func TestCrashDetector_DataRace(t *testing.T) {
+	var races []*Crash
+	d := newCrashDetector(&bytes.Buffer{}, func(c *Crash) { races = append(races, c) })
+	d.Write([]byte(testRaceOutput))
+
+	// Reported when the block ends, not at the exit
+	if len(races) != 1 {
+		t.Fatalf("Expected one race, got %d", len(races))
+	}
+	race := races[0]
+	if race.Kind != CrashDataRace || race.Message != "read by goroutine 8, previous write by main goroutine" {
+		t.Errorf("Unexpected race: %+v", race)
+	}
+	if len(race.Goroutines) != 2 {
+		t.Fatalf("Expected both accesses, got %+v", race.Goroutines)
+	}
+	read, write := race.Goroutines[0], race.Goroutines[1]
+	if read.ID != 8 || read.State != "Read" || len(read.Frames) != 1 || read.Frames[0].Line != 10 {
+		t.Errorf("Unexpected read access: %+v", read)
+	}
+	if read.CreatedBy == nil || *read.CreatedBy != (StackFrame{Function: "main.main", File: "/src/app/main.go", Line: 10}) {
+		t.Errorf("Unexpected creation: %+v", read.CreatedBy)
+	}
+	if write.ID != 1 || write.State != "Previous write" || write.CreatedBy != nil || write.Frames[0].Function != "main.main" {
+		t.Errorf("Unexpected write access: %+v", write)
+	}
+
+	if fatal, last := d.finish(); fatal != nil || last != race {
+		t.Errorf("Expected the race as the last one, got %v and %v", fatal, last)
+	}
+}
+
+// crashEvents collects the EventCrash and EventExited events of a GoRun
+type crashEvents struct {
+	mu     sync.Mutex
+	events []Event
+	exited chan struct{}
+}
+
+func (c *crashEvents) onEvent(ev Event) {
+	if ev.Type != EventCrash && ev.Type != EventExited {
+		return
+	}
+	c.mu.Lock()
+	c.events = append(c.events, ev)
+	c.mu.Unlock()
+	if ev.Type == EventExited {
+		close(c.exited)
+	}
+}
+
+func (c *crashEvents) wait(t *testing.T) []Event {
+	t.Helper()
+	select {
+	case <-c.exited:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Program did not exit")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.events
+}
+
+func TestCrash_PanicInExitInfo(t *testing.T) {
+	execPath := buildTestProgram(t, "panic_program")
+	defer os.Remove(execPath)
+
+	events := &crashEvents{exited: make(chan struct{})}
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool), OnEvent: events.onEvent})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	got := events.wait(t)
+	if len(got) != 2 || got[0].Type != EventCrash || got[1].Type != EventExited {
+		t.Fatalf("Expected a crash then the exit, got %+v", got)
+	}
+	crash := got[0].Crash
+	if crash.Kind != CrashPanic || !strings.Contains(crash.Message, "index out of range [3] with length 3") {
+		t.Errorf("Unexpected crash: %+v", crash)
+	}
+	if f := crash.Location(); f == nil || f.Function != "main.lookup" || filepath.Base(f.File) != "panic_program.go" || f.Line != 7 {
+		t.Errorf("Unexpected location: %+v", f)
+	}
+	if exit := got[1].Exit; exit.Crash != crash || exit.Code != 2 || !strings.Contains(exit.String(), "after panic: ") {
+		t.Errorf("Expected the crash in the exit info, got %v", exit)
+	}
+}
+
+func TestCrash_PanicTextOnSuccessfulExit(t *testing.T) {
+	events := &crashEvents{exited: make(chan struct{})}
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", "printf '%s' \"$0\"", testPanicOutput} },
+		ExitChan:        make(chan bool),
+		OnEvent:         events.onEvent,
+	})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	got := events.wait(t)
+	if len(got) != 1 || got[0].Type != EventExited || got[0].Exit.Crash != nil {
+		t.Errorf("A program exiting with 0 did not crash, got %+v", got)
+	}
+	if !strings.Contains(gr.getOutput(), "panic: runtime error") {
+		t.Errorf("Expected the printed panic in the output, got %q", gr.getOutput())
+	}
+}
+
+func TestCrash_DataRace(t *testing.T) {
+	execPath := filepath.Join(t.TempDir(), "race_program")
+	if out, err := exec.Command("go", "build", "-race", "-o", execPath, filepath.Join("testdata", "race_program.go")).CombinedOutput(); err != nil {
+		t.Skipf("race detector not available: %v\n%s", err, out)
+	}
+
+	events := &crashEvents{exited: make(chan struct{})}
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool), OnEvent: events.onEvent})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	got := events.wait(t)
+	if len(got) < 2 || got[0].Type != EventCrash || got[0].Crash.Kind != CrashDataRace {
+		t.Fatalf("Expected a data race event, got %+v", got)
+	}
+	if f := got[0].Crash.Location(); f == nil || filepath.Base(f.File) != "race_program.go" {
+		t.Errorf("Unexpected location: %+v", f)
+	}
+	// The race detector fails the exit: the race explains it
+	exit := got[len(got)-1].Exit
+	if exit.Code != 66 || exit.Crash == nil || exit.Crash.Kind != CrashDataRace {
+		t.Errorf("Expected the race in the exit info, got %v", exit)
+	}
+}
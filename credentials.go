@@ -0,0 +1,148 @@
+package gorun
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// capabilityNames maps the Linux capability names accepted by Config.AmbientCaps
+var capabilityNames = map[string]uintptr{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// parseCapability parses a capability name like "CAP_NET_BIND_SERVICE" or "net_bind_service"
+func parseCapability(name string) (uintptr, error) {
+	key := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(key, "CAP_") {
+		key = "CAP_" + key
+	}
+	if c, ok := capabilityNames[key]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("unknown capability %q", name)
+}
+
+// credentials is the resolved identity of Config.User, Group and Groups
+type credentials struct {
+	uid, gid uint32
+	groups   []uint32
+}
+
+// resolveCredentials resolves names or numeric ids. Without Group the primary
+// group of User is used; without Groups, the groups User is a member of.
+func (c *Config) resolveCredentials() (*credentials, error) {
+	if c.User == "" && c.Group == "" && len(c.Groups) == 0 {
+		return nil, nil
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("current user: %w", err)
+	}
+	u := current
+	if c.User != "" {
+		if u, err = lookupUser(c.User); err != nil {
+			return nil, err
+		}
+	}
+
+	creds := &credentials{}
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	creds.uid = uint32(uid)
+
+	group := u.Gid
+	if c.Group != "" {
+		if group, err = lookupGroup(c.Group); err != nil {
+			return nil, err
+		}
+	}
+	gid, _ := strconv.ParseUint(group, 10, 32)
+	creds.gid = uint32(gid)
+
+	groups := c.Groups
+	if len(groups) == 0 && c.User != "" {
+		// Like login: the supplementary groups of the user, never those of gorun
+		groups, _ = u.GroupIds()
+	}
+	for _, g := range groups {
+		id, err := lookupGroup(g)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := strconv.ParseUint(id, 10, 32)
+		if uint32(n) != creds.gid {
+			creds.groups = append(creds.groups, uint32(n))
+		}
+	}
+	return creds, nil
+}
+
+// lookupUser finds a user by name, or by id when name is numeric
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		// Ids without a passwd entry are valid, eg: in containers
+		return &user.User{Uid: name, Gid: name, Username: name}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %w", name, err)
+	}
+	return u, nil
+}
+
+// lookupGroup returns the id of a group name, or name itself when numeric
+func lookupGroup(name string) (string, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return name, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", fmt.Errorf("group %q: %w", name, err)
+	}
+	return g.Gid, nil
+}
@@ -0,0 +1,157 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	capSetgid = 6
+	capSetuid = 7
+
+	prSetKeepCaps      = 8
+	prSetNoNewPrivs    = 38
+	prCapAmbient       = 47
+	prCapAmbientRaise  = 2
+	capabilityVersion3 = 0x20080522
+)
+
+// applyCredentials sets the user, groups and ambient capabilities of cmd,
+// failing early when gorun lacks the privileges to switch to them
+func (h *GoRun) applyCredentials(cmd *exec.Cmd) error {
+	creds, err := h.resolveCredentials()
+	if err != nil {
+		return err
+	}
+
+	var ambient []uintptr
+	for _, name := range h.AmbientCaps {
+		c, err := parseCapability(name)
+		if err != nil {
+			return err
+		}
+		ambient = append(ambient, c)
+	}
+	if creds == nil && len(ambient) == 0 {
+		return nil
+	}
+
+	status, err := readProcKeyValues("/proc/self/status")
+	if err != nil {
+		return err
+	}
+	effective, _ := strconv.ParseUint(status["CapEff"], 16, 64)
+	permitted, _ := strconv.ParseUint(status["CapPrm"], 16, 64)
+
+	if creds != nil {
+		if creds.uid != uint32(os.Geteuid()) && effective&(1<<capSetuid) == 0 {
+			return fmt.Errorf("cannot run as uid %d: gorun runs as uid %d without CAP_SETUID", creds.uid, os.Geteuid())
+		}
+		// setgroups needs CAP_SETGID too, even for the current groups
+		if effective&(1<<capSetgid) == 0 {
+			return fmt.Errorf("cannot set gid %d and groups %v: gorun runs without CAP_SETGID", creds.gid, creds.groups)
+		}
+	}
+	for i, c := range ambient {
+		if permitted&(1<<c) == 0 {
+			return fmt.Errorf("cannot grant ambient capability %s: it is not in the permitted set of gorun", h.AmbientCaps[i])
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if creds != nil {
+		// An empty Groups clears the supplementary groups of gorun
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: creds.uid, Gid: creds.gid, Groups: creds.groups}
+	}
+	cmd.SysProcAttr.AmbientCaps = ambient
+	return nil
+}
+
+// startProcess starts cmd. With noNewPrivs it sets PR_SET_NO_NEW_PRIVS on a
+// dedicated OS thread and forks from it, the child inherits the flag; the
+// thread is then discarded by not unlocking it. Linux sends the parent death
+// signal when the thread that forked exits, not the process, so with Pdeathsig
+// the dedicated thread is kept until exited is closed.
+func startProcess(cmd *exec.Cmd, noNewPrivs bool, exited <-chan struct{}) error {
+	pdeathsig := cmd.SysProcAttr != nil && cmd.SysProcAttr.Pdeathsig != 0
+	if !noNewPrivs && !pdeathsig {
+		return cmd.Start()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if noNewPrivs {
+			if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+				result <- fmt.Errorf("no_new_privs: %v", errno)
+				return
+			}
+		}
+		err := cmd.Start()
+		result <- err
+		if err == nil && pdeathsig {
+			<-exited
+		}
+	}()
+	return <-result
+}
+
+// switchThreadCredentials switches the calling thread, locked to its goroutine, to cred
+// and raises the ambient capabilities, as exec.Cmd does in the child. A helper process
+// about to execute the program calls it: exec then drops the other threads.
+func switchThreadCredentials(cred *sandboxCredential, ambient []uintptr) error {
+	if len(ambient) > 0 {
+		// Keep the permitted capabilities across the switch to a non-root user
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetKeepCaps, 1, 0, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("keep capabilities: %v", errno)
+		}
+	}
+	if cred != nil {
+		var groups unsafe.Pointer
+		if len(cred.Groups) > 0 {
+			groups = unsafe.Pointer(&cred.Groups[0])
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, uintptr(len(cred.Groups)), uintptr(groups), 0); errno != 0 {
+			return fmt.Errorf("setgroups: %v", errno)
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGID, uintptr(cred.Gid), 0, 0); errno != 0 {
+			return fmt.Errorf("setgid %d: %v", cred.Gid, errno)
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SETUID, uintptr(cred.Uid), 0, 0); errno != 0 {
+			return fmt.Errorf("setuid %d: %v", cred.Uid, errno)
+		}
+	}
+	if len(ambient) == 0 {
+		return nil
+	}
+
+	// An ambient capability must be both permitted and inheritable
+	header := struct {
+		version uint32
+		pid     int32
+	}{version: capabilityVersion3}
+	var data [2]struct{ effective, permitted, inheritable uint32 }
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capget: %v", errno)
+	}
+	for _, c := range ambient {
+		data[c/32].permitted |= 1 << (c % 32)
+		data[c/32].inheritable |= 1 << (c % 32)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset: %v", errno)
+	}
+	for _, c := range ambient {
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientRaise, c, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("ambient capability %d: %v", c, errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package gorun
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runToExit runs config until the program exits and returns its output
+func runToExit(t *testing.T, config *Config) string {
+	t.Helper()
+	exits := make(chan *ExitInfo, 1)
+	config.ExitChan = make(chan bool)
+	config.OnEvent = func(ev Event) {
+		if ev.Type == EventExited {
+			exits <- ev.Exit
+		}
+	}
+
+	gr := New(config)
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	select {
+	case exit := <-exits:
+		if !exit.Success() {
+			t.Fatalf("Program failed: %v\n%s", exit, gr.getOutput())
+		}
+	case <-time.After(5 * time.Second):
+		gr.StopProgram()
+		t.Fatal("Program did not exit")
+	}
+	return gr.getOutput()
+}
+
+func TestCredentials_RunAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+
+	out := runToExit(t, &Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", "id -u; id -g; id -G"} },
+		WorkingDir:      "/",
+		User:            "nobody",
+	})
+	if got := strings.Fields(out); len(got) != 3 || got[0] != "65534" || got[1] != "65534" || got[2] != "65534" {
+		t.Errorf("Expected uid, gid and groups of nobody, got %q", out)
+	}
+}
+
+func TestCredentials_GroupsCapsAndNoNewPrivs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+
+	out := runToExit(t, &Config{
+		ExecProgramPath: "/bin/cat",
+		RunArguments:    func() []string { return []string{"/proc/self/status"} },
+		WorkingDir:      "/",
+		User:            "4321",
+		Group:           "4322",
+		Groups:          []string{"4323", "4324"},
+		AmbientCaps:     []string{"net_bind_service"},
+		NoNewPrivs:      true,
+	})
+
+	status := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			status[key] = strings.Join(strings.Fields(value), " ")
+		}
+	}
+	for key, want := range map[string]string{
+		"Uid":        "4321 4321 4321 4321",
+		"Gid":        "4322 4322 4322 4322",
+		"Groups":     "4323 4324",
+		"CapAmb":     "0000000000000400",
+		"NoNewPrivs": "1",
+	} {
+		if status[key] != want {
+			t.Errorf("%s: got %q, want %q", key, status[key], want)
+		}
+	}
+}
+
+func TestCredentials_Invalid(t *testing.T) {
+	for _, config := range []*Config{
+		{ExecProgramPath: "true", User: "no-such-user-gorun"},
+		{ExecProgramPath: "true", Group: "no-such-group-gorun"},
+		{ExecProgramPath: "true", AmbientCaps: []string{"CAP_FLY"}},
+	} {
+		if err := New(config).RunProgram(); err == nil {
+			t.Errorf("RunProgram() should fail for %+v", config)
+		}
+	}
+}
+
+func TestParseCapability(t *testing.T) {
+	for _, name := range []string{"CAP_NET_BIND_SERVICE", "net_bind_service", " Net_Bind_Service "} {
+		if c, err := parseCapability(name); err != nil || c != 10 {
+			t.Errorf("parseCapability(%q) = %d, %v", name, c, err)
+		}
+	}
+}
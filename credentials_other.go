@@ -0,0 +1,23 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// applyCredentials rejects User, Group, Groups and AmbientCaps, they are Linux only
+func (h *GoRun) applyCredentials(cmd *exec.Cmd) error {
+	if h.User != "" || h.Group != "" || len(h.Groups) > 0 || len(h.AmbientCaps) > 0 {
+		return errors.New("running as another user or with ambient capabilities is only supported on Linux")
+	}
+	return nil
+}
+
+func startProcess(cmd *exec.Cmd, noNewPrivs bool, exited <-chan struct{}) error {
+	if noNewPrivs {
+		return errors.New("no_new_privs is only supported on Linux")
+	}
+	return cmd.Start()
+}
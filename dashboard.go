@@ -0,0 +1,322 @@
+package gorun
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Dashboard is an http.Handler showing the status and live output of programs,
+// with restart and stop buttons. Mount it under a prefix with http.StripPrefix:
+//
+//	mux.Handle("/gorun/", http.StripPrefix("/gorun", gorun.NewDashboard(m)))
+//
+// Routes: GET / (HTML page), GET /api/status (JSON), POST /api/restart?name=,
+// POST /api/stop?name= and GET /api/logs?name=&lines= (Server-Sent Events).
+// Cross-origin POST requests are rejected: another site open in the browser
+// can't restart or stop programs.
+type Dashboard struct {
+	names   func() []string
+	get     func(name string) *GoRun
+	restart func(name string) error
+	stop    func(name string) error
+	mux     *http.ServeMux
+}
+
+// DashboardProgram is one entry of the dashboard status API
+type DashboardProgram struct {
+	Name string `json:"name"`
+	Status
+	Resources *ResourceStats `json:"resources,omitempty"` // Linux only, nil when stopped
+}
+
+// NewDashboard returns a Dashboard for every program of m. Restart and stop
+// cascade to dependants like Manager.Restart and Manager.Stop.
+func NewDashboard(m *Manager) *Dashboard {
+	return newDashboard(m.Names, m.Get, m.Restart, m.Stop)
+}
+
+// NewProgramDashboard returns a Dashboard for a single program shown as name
+func NewProgramDashboard(name string, h *GoRun) *Dashboard {
+	get := func(n string) *GoRun {
+		if n == name {
+			return h
+		}
+		return nil
+	}
+	return newDashboard(
+		func() []string { return []string{name} },
+		get,
+		func(string) error { return h.RunProgram() },
+		func(string) error { return h.StopProgram() },
+	)
+}
+
+func newDashboard(names func() []string, get func(string) *GoRun, restart, stop func(string) error) *Dashboard {
+	d := &Dashboard{names: names, get: get, restart: restart, stop: stop}
+
+	d.mux = http.NewServeMux()
+	d.mux.HandleFunc("GET /{$}", d.servePage)
+	d.mux.HandleFunc("GET /api/status", d.serveStatus)
+	d.mux.HandleFunc("POST /api/restart", d.serveAction(d.restart))
+	d.mux.HandleFunc("POST /api/stop", d.serveAction(d.stop))
+	d.mux.HandleFunc("GET /api/logs", d.serveLogs)
+	return d
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// Programs returns the status of every program, as served by /api/status
+func (d *Dashboard) Programs() []DashboardProgram {
+	names := d.names()
+	programs := make([]DashboardProgram, 0, len(names))
+	for _, name := range names {
+		h := d.get(name)
+		if h == nil {
+			continue
+		}
+		p := DashboardProgram{Name: name, Status: h.Status()}
+		if stats, err := h.Stats(); err == nil {
+			p.Resources = &stats
+		}
+		programs = append(programs, p)
+	}
+	return programs
+}
+
+func (d *Dashboard) servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardPage.Execute(w, d.Programs()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (d *Dashboard) serveStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.Programs())
+}
+
+// serveAction runs action on the program named by the name query parameter
+func (d *Dashboard) serveAction(action func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isCrossOrigin(r) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "cross-origin request rejected"})
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if d.get(name) == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown process %q", name)})
+			return
+		}
+		if err := action(name); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{})
+	}
+}
+
+// isCrossOrigin reports whether a browser sent r from another site, like
+// http.CrossOriginProtection: by Sec-Fetch-Site, else by the Origin host.
+// Requests without either header don't come from a browser and are allowed.
+func isCrossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "":
+	case "same-origin", "none":
+		return false
+	default:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// serveLogs streams the output of a program as Server-Sent Events, one event per line
+func (d *Dashboard) serveLogs(w http.ResponseWriter, r *http.Request) {
+	h := d.get(r.URL.Query().Get("name"))
+	if h == nil {
+		http.NotFound(w, r)
+		return
+	}
+	lines := defaultTailLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid lines", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+
+	tail, output, cancel := h.FollowOutput(lines)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	send := func(chunk string) error {
+		for _, line := range strings.Split(strings.TrimSuffix(chunk, "\n"), "\n") {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", strings.TrimSuffix(line, "\r")); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	}
+
+	if tail != "" {
+		if err := send(tail); err != nil {
+			return
+		}
+	} else if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case p := <-output:
+			if err := send(string(p)); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// dashboardPage uses relative URLs so the Dashboard works under any prefix
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gorun</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; }
+table { border-collapse: collapse; }
+th, td { padding: .3em .8em; text-align: left; border-bottom: 1px solid #ddd; }
+tr.selected { background: #eef; }
+.running { color: #080; } .starting { color: #a60; } .stopped { color: #a00; }
+pre { background: #111; color: #ddd; padding: .8em; height: 24em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>gorun</h1>
+<table>
+<thead><tr><th>Name</th><th>State</th><th>PID</th><th>Uptime</th><th>Restarts</th><th>Last exit</th><th>CPU</th><th>Memory</th><th>FDs</th><th></th></tr></thead>
+<tbody id="programs">
+{{range .}}<tr data-name="{{.Name}}"><td>{{.Name}}</td><td class="{{.State}}">{{.State}}</td><td>{{.PID}}</td><td></td><td>{{.Restarts}}</td><td>{{if .LastExit}}{{.LastExit}}{{end}}</td><td></td><td></td><td></td><td></td></tr>
+{{end}}</tbody>
+</table>
+<h2 id="log-title"></h2>
+<pre id="log"></pre>
+<script>
+var selected = "", source = null;
+
+function uptime(ns) {
+  var s = Math.floor(ns / 1e9);
+  if (s < 60) return s + "s";
+  if (s < 3600) return Math.floor(s / 60) + "m" + (s % 60) + "s";
+  return Math.floor(s / 3600) + "h" + Math.floor(s % 3600 / 60) + "m";
+}
+
+function exitText(e) {
+  if (!e) return "";
+  var text = e.signal ? "signal " + e.signal : "code " + e.code;
+  if (e.crash) text += ", " + e.crash.kind + ": " + e.crash.message.split("\n")[0];
+  return text;
+}
+
+function bytes(n) {
+  var units = ["B", "KiB", "MiB", "GiB"], i = 0;
+  while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+  return n.toFixed(i ? 1 : 0) + " " + units[i];
+}
+
+function action(name, what) {
+  fetch("api/" + what + "?name=" + encodeURIComponent(name), {method: "POST"})
+    .then(function (r) { return r.json(); })
+    .then(function (r) { if (r.error) alert(r.error); refresh(); });
+}
+
+function follow(name) {
+  if (source) source.close();
+  selected = name;
+  var log = document.getElementById("log");
+  log.textContent = "";
+  document.getElementById("log-title").textContent = name;
+  source = new EventSource("api/logs?name=" + encodeURIComponent(name));
+  source.onmessage = function (ev) {
+    var bottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+    log.textContent += ev.data + "\n";
+    if (bottom) log.scrollTop = log.scrollHeight;
+  };
+  refresh();
+}
+
+function cell(text, cls) {
+  var td = document.createElement("td");
+  td.textContent = text;
+  if (cls) td.className = cls;
+  return td;
+}
+
+function button(label, onclick) {
+  var b = document.createElement("button");
+  b.textContent = label;
+  b.onclick = function (ev) { ev.stopPropagation(); onclick(); };
+  return b;
+}
+
+function refresh() {
+  fetch("api/status").then(function (r) { return r.json(); }).then(function (programs) {
+    var body = document.getElementById("programs");
+    body.textContent = "";
+    programs.forEach(function (p) {
+      var tr = document.createElement("tr");
+      if (p.name === selected) tr.className = "selected";
+      tr.onclick = function () { follow(p.name); };
+      tr.appendChild(cell(p.name));
+      tr.appendChild(cell(p.state, p.state));
+      tr.appendChild(cell(p.pid || ""));
+      tr.appendChild(cell(p.uptime ? uptime(p.uptime) : ""));
+      tr.appendChild(cell(p.restarts));
+      var exit = cell(exitText(p.last_exit));
+      // The full report on hover
+      if (p.last_exit && p.last_exit.crash) exit.title = p.last_exit.crash.report;
+      tr.appendChild(exit);
+      var r = p.resources;
+      tr.appendChild(cell(r ? r.cpu_percent.toFixed(1) + "%" : ""));
+      tr.appendChild(cell(r ? bytes(r.rss) : ""));
+      tr.appendChild(cell(r ? r.fds : ""));
+      var td = cell("");
+      td.appendChild(button("Restart", function () { action(p.name, "restart"); }));
+      td.appendChild(button("Stop", function () { action(p.name, "stop"); }));
+      tr.appendChild(td);
+      body.appendChild(tr);
+    });
+    if (!selected && programs.length > 0) follow(programs[0].name);
+  });
+}
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,166 @@
+package gorun
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard_StatusAndActions(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	m := NewManager(nil)
+	m.Add("api", &Config{ExecProgramPath: execPath})
+	m.Add("worker", &Config{ExecProgramPath: execPath})
+	defer m.StopAll()
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/gorun/", http.StripPrefix("/gorun", NewDashboard(m)))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/gorun/")
+	if err != nil {
+		t.Fatalf("GET page failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Unexpected page response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	programs := getPrograms(t, srv.URL+"/gorun/api/status")
+	if len(programs) != 2 || programs[0].Name != "api" || programs[0].State != StateRunning || programs[0].PID == 0 {
+		t.Fatalf("Unexpected status: %+v", programs)
+	}
+	oldPID := programs[0].PID
+
+	resp, err = http.Post(srv.URL+"/gorun/api/restart?name=api", "", nil)
+	if err != nil {
+		t.Fatalf("POST restart failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 on restart, got %d", resp.StatusCode)
+	}
+	if pid := m.Get("api").GetPID(); pid == 0 || pid == oldPID {
+		t.Errorf("api should have been restarted, old=%d new=%d", oldPID, pid)
+	}
+
+	resp, err = http.Post(srv.URL+"/gorun/api/stop?name=worker", "", nil)
+	if err != nil {
+		t.Fatalf("POST stop failed: %v", err)
+	}
+	resp.Body.Close()
+	if programs := getPrograms(t, srv.URL+"/gorun/api/status"); programs[1].State != StateStopped || programs[1].LastExit == nil {
+		t.Errorf("worker should be stopped with its last exit, got %+v", programs[1])
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/gorun/api/stop?name=api", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/gorun/api/restart?name=missing", http.StatusNotFound},
+		{http.MethodGet, "/gorun/api/logs?name=missing", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.code, resp.StatusCode)
+		}
+	}
+}
+
+func TestDashboard_RejectsCrossOriginActions(t *testing.T) {
+	srv := httptest.NewServer(NewProgramDashboard("app", New(&Config{ExecProgramPath: "test"})))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		header, value string
+		code          int
+	}{
+		{"Sec-Fetch-Site", "cross-site", http.StatusForbidden},
+		{"Sec-Fetch-Site", "same-site", http.StatusForbidden},
+		{"Origin", "http://evil.example", http.StatusForbidden},
+		{"Origin", "null", http.StatusForbidden},
+		{"Sec-Fetch-Site", "same-origin", http.StatusOK},
+		{"Origin", srv.URL, http.StatusOK},
+		{"", "", http.StatusOK},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/stop?name=app", nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST stop with %s: %s failed: %v", tc.header, tc.value, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("POST stop with %s: %s: expected %d, got %d", tc.header, tc.value, tc.code, resp.StatusCode)
+		}
+	}
+}
+
+func TestDashboard_LogStream(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool)})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	srv := httptest.NewServer(NewProgramDashboard("app", gr))
+	defer srv.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(srv.URL + "/api/logs?name=app&lines=0")
+	if err != nil {
+		t.Fatalf("GET logs failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			if !strings.HasPrefix(line, "data: LONG_") {
+				t.Errorf("Unexpected event line %q", line)
+			}
+			return
+		}
+	}
+	t.Fatalf("Log stream ended without events: %v", scanner.Err())
+}
+
+func getPrograms(t *testing.T, url string) []DashboardProgram {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET status failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var programs []DashboardProgram
+	if err := json.NewDecoder(resp.Body).Decode(&programs); err != nil {
+		t.Fatalf("Invalid status JSON: %v", err)
+	}
+	return programs
+}
@@ -0,0 +1,156 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DebugConfig runs the program under Delve instead of directly:
+//
+//	dlv exec --headless --listen=<addr> --api-version=2 --accept-multiclient <program> -- <args>
+//
+// The program is ready once the debug server accepts connections, paused or not:
+// ReadinessProbe is not used.
+// GetPID and Stats are those of dlv. StopProgram sends SIGINT to dlv, which kills
+// the program; a program left behind by a forced stop is killed too (Linux).
+// Signal and DumpGoroutines return ErrDebugMode: the signals would reach dlv.
+type DebugConfig struct {
+	Listen   string   // Address of the debug server (default: a free localhost port, kept across restarts)
+	Dlv      string   // dlv executable (default: "dlv")
+	Continue bool     // Run the program right away instead of waiting for a client to continue it
+	Args     []string // Extra dlv flags, eg: []string{"--log"}
+}
+
+// ErrDebugMode is returned by the operations on the program process that can't go
+// through dlv, see DebugConfig
+var ErrDebugMode = errors.New("not available in debug mode: the process is dlv, not the program")
+
+// debugConnectTimeout bounds one connection attempt to the debug server
+const debugConnectTimeout = time.Second
+
+func (c *DebugConfig) dlv() string {
+	if c.Dlv != "" {
+		return c.Dlv
+	}
+	return "dlv"
+}
+
+// command returns the dlv command line running program with args
+func (c *DebugConfig) command(addr, program string, args []string) (string, []string) {
+	dlvArgs := []string{"exec", "--headless", "--listen=" + addr, "--api-version=2", "--accept-multiclient"}
+	if c.Continue {
+		dlvArgs = append(dlvArgs, "--continue")
+	}
+	dlvArgs = append(dlvArgs, c.Args...)
+	dlvArgs = append(dlvArgs, program, "--")
+	return c.dlv(), append(dlvArgs, args...)
+}
+
+// debugTargets holds the processes started by every live dlv, killed when it exits
+type debugTargets struct {
+	mutex   sync.Mutex
+	handles map[int][]*processHandle
+}
+
+// debugAddrUnsafe returns the address of the debug server, allocating one the first time
+// Should only be called when mutex is already held
+func (h *GoRun) debugAddrUnsafe() (string, error) {
+	if h.Debug.Listen != "" {
+		return h.Debug.Listen, nil
+	}
+	if h.debugAddr == "" {
+		ports, err := allocatePorts([]string{"dlv"})
+		if err != nil {
+			return "", fmt.Errorf("debug: %v", err)
+		}
+		h.debugAddr = fmt.Sprintf("127.0.0.1:%d", ports["dlv"])
+	}
+	return h.debugAddr, nil
+}
+
+// debugCommand returns the command starting program with args, under dlv in debug mode
+// Should only be called when mutex is already held
+func (h *GoRun) debugCommand(program string, args []string) (string, []string, error) {
+	if h.Debug == nil {
+		return program, args, nil
+	}
+	if h.RestartStrategy == RestartBlueGreen {
+		return "", nil, errors.New("debug: RestartBlueGreen can't run two instances on one debug address")
+	}
+	addr, err := h.debugAddrUnsafe()
+	if err != nil {
+		return "", nil, err
+	}
+	path, dlvArgs := h.Debug.command(addr, program, args)
+	return path, dlvArgs, nil
+}
+
+// debugProbe is the ReadinessProbe of debug mode: the debug server accepts
+// connections, dlv has started the program by then
+func (h *GoRun) debugProbe(addr string) func(pid int) error {
+	return func(pid int) error {
+		conn, err := net.DialTimeout("tcp", addr, debugConnectTimeout)
+		if err != nil {
+			return fmt.Errorf("debug server not listening on %s: %v", addr, err)
+		}
+		conn.Close()
+		h.trackDebugTargets(pid)
+		return nil
+	}
+}
+
+// trackDebugTargets opens a handle on every descendant of dlv, while it holds them
+func (h *GoRun) trackDebugTargets(pid int) {
+	var handles []*processHandle
+	for _, target := range processDescendants(pid) {
+		if handle, err := openProcessHandle(target); err == nil {
+			handles = append(handles, handle)
+		}
+	}
+
+	h.debug.mutex.Lock()
+	defer h.debug.mutex.Unlock()
+	if h.debug.handles == nil {
+		h.debug.handles = make(map[int][]*processHandle)
+	}
+	h.debug.handles[pid] = handles
+}
+
+// killDebugTargets kills what a dlv that exited left behind, eg: after a forced stop
+func (h *GoRun) killDebugTargets(pid int) {
+	h.debug.mutex.Lock()
+	handles := h.debug.handles[pid]
+	delete(h.debug.handles, pid)
+	h.debug.mutex.Unlock()
+
+	for _, handle := range handles {
+		if err := handle.signal(syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: debug: killing the program left by dlv %d: %v\n", pid, err)))
+		}
+		handle.close()
+	}
+}
+
+// DebugAddr returns the address of the debug server while the program runs in debug mode
+func (h *GoRun) DebugAddr() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.activeDebugAddrUnsafe()
+}
+
+// activeDebugAddrUnsafe returns the address of the running debug server, empty if none
+// Should only be called when mutex is already held
+func (h *GoRun) activeDebugAddrUnsafe() string {
+	if h.Debug == nil || !h.isRunning || h.attached {
+		return ""
+	}
+	if h.Debug.Listen != "" {
+		return h.Debug.Listen
+	}
+	return h.debugAddr
+}
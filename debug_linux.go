@@ -0,0 +1,6 @@
+package gorun
+
+// processDescendants returns every descendant of pid
+func processDescendants(pid int) []int {
+	return processTree(pid)[1:]
+}
@@ -0,0 +1,156 @@
+package gorun
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startFakeDlv runs sleep under testdata/fake_dlv, a stand-in for dlv
+func startFakeDlv(t *testing.T, env []string, stopTimeout time.Duration) *GoRun {
+	t.Helper()
+	dlv := buildTestProgram(t, "fake_dlv")
+	t.Cleanup(func() { os.Remove(dlv) })
+
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"30"} },
+		ExitChan:        make(chan bool),
+		Env:             env,
+		StopTimeout:     stopTimeout,
+		// Never used in debug mode
+		ReadinessProbe: func(int) error { return os.ErrNotExist },
+		Debug:          &DebugConfig{Dlv: dlv, Continue: true},
+	})
+	t.Cleanup(func() { gr.StopProgram() })
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v\n%s", err, gr.getOutput())
+	}
+	return gr
+}
+
+// waitTargetsGone fails unless every pid exits shortly
+func waitTargetsGone(t *testing.T, pids []int) {
+	t.Helper()
+	for _, pid := range pids {
+		for deadline := time.Now().Add(2 * time.Second); !processGone(pid) && time.Now().Before(deadline); {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if !processGone(pid) {
+			t.Errorf("Program %d survived the stop of dlv", pid)
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+func TestDebug_FakeDlv(t *testing.T) {
+	gr := startFakeDlv(t, nil, 0)
+
+	addr := gr.DebugAddr()
+	if addr == "" || gr.Status().DebugAddr != addr || gr.Status().State != StateRunning {
+		t.Fatalf("Expected a running program with its debug address, got %+v", gr.Status())
+	}
+	if out := gr.getOutput(); !strings.Contains(out, "FAKE_DLV_ARGS exec --headless --listen="+addr+" --api-version=2 --accept-multiclient --continue sleep -- 30") {
+		t.Errorf("Unexpected dlv command line: %s", out)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Debug server not reachable: %v", err)
+	}
+	conn.Close()
+
+	targets := processDescendants(gr.GetPID())
+	if len(targets) != 1 {
+		t.Fatalf("Expected sleep as the only child of dlv, got %v", targets)
+	}
+
+	// The address is kept across restarts
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	if gr.DebugAddr() != addr {
+		t.Errorf("Expected the same debug address %s, got %s", addr, gr.DebugAddr())
+	}
+	waitTargetsGone(t, targets)
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	// SIGINT, not the kill after StopTimeout
+	if exit := gr.LastExit(); exit == nil || exit.Signal != nil || exit.Code != 0 {
+		t.Errorf("Expected dlv to exit on SIGINT, got %v", exit)
+	}
+	if gr.DebugAddr() != "" {
+		t.Error("DebugAddr() should be empty once stopped")
+	}
+}
+
+func TestDebug_ForcedStopKillsProgram(t *testing.T) {
+	gr := startFakeDlv(t, []string{"FAKE_DLV_IGNORE_INT=1"}, 200*time.Millisecond)
+
+	targets := processDescendants(gr.GetPID())
+	if len(targets) != 1 {
+		t.Fatalf("Expected sleep as the only child of dlv, got %v", targets)
+	}
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if exit := gr.LastExit(); exit == nil || exit.Signal != syscall.SIGKILL {
+		t.Errorf("Expected dlv to be killed, got %v", exit)
+	}
+	// dlv was killed, gorun kills the program it left behind
+	waitTargetsGone(t, targets)
+}
+
+func TestDebug_Delve(t *testing.T) {
+	dlv, err := exec.LookPath("dlv")
+	if err != nil {
+		t.Skip("dlv not installed")
+	}
+
+	execPath := filepath.Join(t.TempDir(), "long_program")
+	if out, err := exec.Command("go", "build", "-gcflags=all=-N -l", "-o", execPath, filepath.Join("testdata", "long_program.go")).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build long_program: %v\n%s", err, out)
+	}
+
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		Debug:           &DebugConfig{Dlv: dlv},
+	})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v\n%s", err, gr.getOutput())
+	}
+
+	// Paused at the entry point, waiting for a client: running anyway
+	status := gr.Status()
+	if status.State != StateRunning || status.DebugAddr == "" || status.DebugAddr != gr.DebugAddr() {
+		t.Fatalf("Expected a running program with its debug address, got %+v", status)
+	}
+	conn, err := net.Dial("tcp", gr.DebugAddr())
+	if err != nil {
+		t.Fatalf("Debug server not reachable: %v", err)
+	}
+	conn.Close()
+
+	targets := processDescendants(gr.GetPID())
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if exit := gr.LastExit(); exit == nil || !exit.Requested {
+		t.Errorf("Expected a requested exit, got %v", exit)
+	}
+	if len(targets) == 0 {
+		t.Error("Expected the program as a child of dlv")
+	}
+	waitTargetsGone(t, targets)
+	if gr.DebugAddr() != "" {
+		t.Error("DebugAddr() should be empty once stopped")
+	}
+}
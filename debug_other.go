@@ -0,0 +1,8 @@
+//go:build !linux
+
+package gorun
+
+// processDescendants can't list descendants without /proc, dlv alone stops the program
+func processDescendants(pid int) []int {
+	return nil
+}
@@ -0,0 +1,49 @@
+package gorun
+
+import (
+	"errors"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func TestDebugConfig_Command(t *testing.T) {
+	path, args := (&DebugConfig{}).command("127.0.0.1:2345", "./server", []string{"-dev"})
+	expected := []string{"exec", "--headless", "--listen=127.0.0.1:2345", "--api-version=2", "--accept-multiclient", "./server", "--", "-dev"}
+	if path != "dlv" || !slices.Equal(args, expected) {
+		t.Errorf("Unexpected command %s %v", path, args)
+	}
+
+	path, args = (&DebugConfig{Dlv: "/opt/dlv", Continue: true, Args: []string{"--log"}}).command(":2345", "server", nil)
+	expected = []string{"exec", "--headless", "--listen=:2345", "--api-version=2", "--accept-multiclient", "--continue", "--log", "server", "--"}
+	if path != "/opt/dlv" || !slices.Equal(args, expected) {
+		t.Errorf("Unexpected command %s %v", path, args)
+	}
+}
+
+func TestDebug_BlueGreen(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		ExitChan:        make(chan bool),
+		RestartStrategy: RestartBlueGreen,
+		Debug:           &DebugConfig{Dlv: "dlv"},
+	})
+	if err := gr.RunProgram(); err == nil {
+		gr.StopProgram()
+		t.Error("Debug mode should refuse RestartBlueGreen")
+	}
+}
+
+func TestDebug_SignalsRefused(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		ExitChan:        make(chan bool),
+		Debug:           &DebugConfig{Dlv: "dlv"},
+	})
+	if err := gr.Signal(syscall.SIGHUP); !errors.Is(err, ErrDebugMode) {
+		t.Errorf("Signal() in debug mode: expected ErrDebugMode, got %v", err)
+	}
+	if _, err := gr.DumpGoroutines(false); !errors.Is(err, ErrDebugMode) {
+		t.Errorf("DumpGoroutines() in debug mode: expected ErrDebugMode, got %v", err)
+	}
+}
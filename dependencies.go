@@ -0,0 +1,282 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultDependencyTimeout bounds the wait for a Dependency without Timeout
+const defaultDependencyTimeout = time.Minute
+
+// ErrDependencyTimeout is returned, wrapped, when a dependency doesn't reach its condition in time
+var ErrDependencyTimeout = errors.New("dependency timeout")
+
+// DependencyCondition is the state a dependency must reach before its dependant starts
+type DependencyCondition int
+
+const (
+	DependencyStarted               DependencyCondition = iota // The dependency process was started
+	DependencyReady                                            // The dependency passed its ReadinessProbe
+	DependencyCompletedSuccessfully                            // The dependency exited with code 0, eg: a migration job
+)
+
+func (c DependencyCondition) String() string {
+	switch c {
+	case DependencyStarted:
+		return "started"
+	case DependencyReady:
+		return "ready"
+	case DependencyCompletedSuccessfully:
+		return "completed-successfully"
+	}
+	return "unknown"
+}
+
+// Dependency declares that a program needs the program registered as Name
+// in the same Manager to reach Condition before being started
+type Dependency struct {
+	Name      string
+	Condition DependencyCondition
+	Timeout   time.Duration // Max wait for Condition (default: 1m)
+}
+
+// procRecord is the lifecycle state of the current run of a managed program
+type procRecord struct {
+	pid      int
+	started  bool
+	ready    bool
+	exited   bool
+	exitCode int
+	failed   error // RunProgram or one of its dependencies failed
+}
+
+// startOrder returns every registered name with dependencies before their
+// dependants, keeping Add order otherwise. Unknown names and cycles are errors.
+func (m *Manager) startOrder() ([]string, error) {
+	names := m.Names()
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			cycle := append(path[indexOf(path, name):], name)
+			return fmt.Errorf("gorun manager: dependency cycle %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range m.Get(name).DependsOn {
+			if m.Get(dep.Name) == nil {
+				return fmt.Errorf("gorun manager: %s depends on unknown process %q", name, dep.Name)
+			}
+			if err := visit(dep.Name, path); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// dependantsOf returns the programs that depend, directly or transitively, on name, in start order
+func (m *Manager) dependantsOf(name string, order []string) []string {
+	affected := map[string]bool{name: true}
+	var dependants []string
+
+	// order has dependencies first, so one pass reaches every transitive dependant
+	for _, candidate := range order {
+		for _, dep := range m.Get(candidate).DependsOn {
+			if affected[dep.Name] && !affected[candidate] {
+				affected[candidate] = true
+				dependants = append(dependants, candidate)
+			}
+		}
+	}
+	return dependants
+}
+
+// resetRecords forgets the previous run of names so dependants wait for the new one
+func (m *Manager) resetRecords(names []string) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	for _, name := range names {
+		m.records[name] = &procRecord{}
+	}
+	m.stateCond.Broadcast()
+}
+
+func (m *Manager) markFailed(name string, err error) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	m.records[name].failed = err
+	m.stateCond.Broadcast()
+}
+
+// recordEvent updates the record of name from one of its GoRun events
+func (m *Manager) recordEvent(name string, ev Event) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	rec := m.records[name]
+	if rec == nil {
+		rec = &procRecord{}
+		m.records[name] = rec
+	}
+
+	switch ev.Type {
+	case EventStarted:
+		// The Manager starts programs from a fresh record: this restart is someone else's
+		if rec.pid != 0 {
+			m.cascading++
+			go func() {
+				defer m.cascadeDone()
+				m.restartDependants(name)
+			}()
+		}
+		rec.pid = ev.PID
+		rec.started = true
+		rec.ready = false
+		rec.exited = false
+	case EventReady:
+		if ev.PID == rec.pid {
+			rec.ready = true
+		}
+	case EventExited:
+		// Ignore a replaced instance exiting after the new one started
+		if ev.PID == rec.pid {
+			rec.started = false
+			rec.ready = false
+			rec.exited = true
+			rec.exitCode = ev.Exit.Code
+		}
+	}
+	m.stateCond.Broadcast()
+}
+
+// restartDependants restarts the running dependants of name, started again outside
+// of the Manager, once it reaches their condition again
+func (m *Manager) restartDependants(name string) {
+	m.cascadeMutex.Lock()
+	defer m.cascadeMutex.Unlock()
+
+	order, err := m.startOrder()
+	if err != nil {
+		return
+	}
+	var running []string
+	for _, dependant := range m.dependantsOf(name, order) {
+		if m.Get(dependant).IsRunning() {
+			running = append(running, dependant)
+		}
+	}
+	if len(running) == 0 {
+		return
+	}
+
+	err = m.stop(running)
+	if err == nil {
+		err = m.start(running)
+	}
+	if err != nil && m.Logger != nil {
+		m.Logger(fmt.Sprintf("gorun manager: restarting the dependants of %s: %v", name, err))
+	}
+}
+
+// cascadeDone records the end of a restartDependants call
+func (m *Manager) cascadeDone() {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	m.cascading--
+	m.stateCond.Broadcast()
+}
+
+// waitFor blocks until dep reaches its condition, can no longer reach it or its
+// Timeout passes
+func (m *Manager) waitFor(dep Dependency) error {
+	timeout := dep.Timeout
+	if timeout <= 0 {
+		timeout = defaultDependencyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	// Wake the wait below once the deadline passed
+	timer := time.AfterFunc(timeout, func() {
+		m.stateMutex.Lock()
+		defer m.stateMutex.Unlock()
+		m.stateCond.Broadcast()
+	})
+	defer timer.Stop()
+
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	for {
+		rec := m.records[dep.Name]
+		if rec == nil {
+			rec = &procRecord{}
+		}
+
+		if rec.failed != nil {
+			return fmt.Errorf("dependency %s failed: %w", dep.Name, rec.failed)
+		}
+
+		switch dep.Condition {
+		case DependencyStarted:
+			if rec.started {
+				return nil
+			}
+			if rec.exited {
+				return fmt.Errorf("dependency %s exited with code %d", dep.Name, rec.exitCode)
+			}
+		case DependencyReady:
+			if rec.ready {
+				return nil
+			}
+			if rec.exited {
+				return fmt.Errorf("dependency %s exited with code %d before being ready", dep.Name, rec.exitCode)
+			}
+		case DependencyCompletedSuccessfully:
+			if rec.exited {
+				if rec.exitCode == 0 {
+					return nil
+				}
+				return fmt.Errorf("dependency %s exited with code %d", dep.Name, rec.exitCode)
+			}
+		default:
+			return fmt.Errorf("dependency %s: unknown condition %d", dep.Name, dep.Condition)
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("dependency %s not %s after %v: %w", dep.Name, dep.Condition, timeout, ErrDependencyTimeout)
+		}
+		m.stateCond.Wait()
+	}
+}
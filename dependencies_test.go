@@ -0,0 +1,269 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventLog records "name:event" entries from several programs in arrival order
+type eventLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *eventLog) handler(name string) func(Event) {
+	return func(ev Event) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.entries = append(l.entries, name+":"+ev.Type.String())
+	}
+}
+
+// index returns the position of entry, or -1
+func (l *eventLog) index(entry string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return indexOf(l.entries, entry)
+}
+
+func TestDependencies_Cycle(t *testing.T) {
+	m := NewManager(nil)
+	m.Add("a", &Config{ExecProgramPath: "test", DependsOn: []Dependency{{Name: "b"}}})
+	m.Add("b", &Config{ExecProgramPath: "test", DependsOn: []Dependency{{Name: "a"}}})
+
+	err := m.StartAll()
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+}
+
+func TestDependencies_Unknown(t *testing.T) {
+	m := NewManager(nil)
+	m.Add("api", &Config{ExecProgramPath: "test", DependsOn: []Dependency{{Name: "db"}}})
+
+	if err := m.StartAll(); err == nil || !strings.Contains(err.Error(), `"db"`) {
+		t.Errorf("Expected unknown dependency error, got %v", err)
+	}
+}
+
+func TestDependencies_StartStopOrder(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	for _, ordered := range []bool{false, true} {
+		events := &eventLog{}
+		m := NewManager(&ManagerConfig{Ordered: ordered})
+
+		// Added in reverse so Add order alone would be wrong
+		m.Add("web", &Config{ExecProgramPath: execPath, OnEvent: events.handler("web"),
+			DependsOn: []Dependency{{Name: "api", Condition: DependencyStarted}}})
+		m.Add("api", &Config{ExecProgramPath: execPath, OnEvent: events.handler("api"),
+			DependsOn: []Dependency{{Name: "db", Condition: DependencyReady}}})
+		m.Add("db", &Config{ExecProgramPath: execPath, OnEvent: events.handler("db")})
+
+		if err := m.StartAll(); err != nil {
+			t.Fatalf("StartAll() failed: %v", err)
+		}
+		if err := m.StopAll(); err != nil {
+			t.Errorf("StopAll() failed: %v", err)
+		}
+
+		if !(events.index("db:ready") < events.index("api:started") && events.index("api:started") < events.index("web:started")) {
+			t.Errorf("ordered=%v: wrong start order: %v", ordered, events.entries)
+		}
+		if !(events.index("web:exited") < events.index("api:exited") && events.index("api:exited") < events.index("db:exited")) {
+			t.Errorf("ordered=%v: wrong stop order: %v", ordered, events.entries)
+		}
+	}
+}
+
+func TestDependencies_CompletedSuccessfully(t *testing.T) {
+	okPath := buildTestProgram(t, "args_program")
+	defer os.Remove(okPath)
+	failPath := buildTestProgram(t, "error_program")
+	defer os.Remove(failPath)
+	longPath := buildTestProgram(t, "long_program")
+	defer os.Remove(longPath)
+
+	events := &eventLog{}
+	m := NewManager(nil)
+	m.Add("migrate", &Config{ExecProgramPath: okPath, OnEvent: events.handler("migrate")})
+	m.Add("api", &Config{ExecProgramPath: longPath, OnEvent: events.handler("api"),
+		DependsOn: []Dependency{{Name: "migrate", Condition: DependencyCompletedSuccessfully}}})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	if events.index("migrate:exited") > events.index("api:started") {
+		t.Errorf("api should start after migrate completed: %v", events.entries)
+	}
+
+	failing := NewManager(nil)
+	failing.Add("migrate", &Config{ExecProgramPath: failPath})
+	failing.Add("api", &Config{ExecProgramPath: longPath,
+		DependsOn: []Dependency{{Name: "migrate", Condition: DependencyCompletedSuccessfully}}})
+
+	err := failing.StartAll()
+	if err == nil || !strings.Contains(err.Error(), "exited with code 1") {
+		t.Errorf("Expected failed dependency error, got %v", err)
+	}
+	if failing.Get("api").IsRunning() {
+		t.Error("api should not start when its dependency failed")
+	}
+}
+
+func TestDependencies_Timeout(t *testing.T) {
+	m := NewManager(nil)
+	m.Add("db", &Config{
+		ExecProgramPath:  "sleep",
+		RunArguments:     func() []string { return []string{"30"} },
+		ReadinessProbe:   func(pid int) error { return errors.New("not ready yet") },
+		ReadinessTimeout: 2 * time.Second,
+	})
+	m.Add("api", &Config{ExecProgramPath: "sleep", RunArguments: func() []string { return []string{"30"} },
+		DependsOn: []Dependency{{Name: "db", Condition: DependencyReady, Timeout: 200 * time.Millisecond}}})
+	defer m.StopAll()
+
+	err := m.StartAll()
+	if !errors.Is(err, ErrDependencyTimeout) || !strings.Contains(err.Error(), "dependency db not ready after 200ms") {
+		t.Errorf("Expected a dependency timeout, got %v", err)
+	}
+	if m.Get("api").IsRunning() {
+		t.Error("api should not start when its dependency timed out")
+	}
+}
+
+func TestDependencies_ExitedDependencyIsNotReady(t *testing.T) {
+	m := NewManager(nil)
+	m.Add("db", &Config{ExecProgramPath: "sleep", RunArguments: func() []string { return []string{"0.2"} }})
+	m.Add("api", &Config{ExecProgramPath: "sleep", RunArguments: func() []string { return []string{"30"} },
+		DependsOn: []Dependency{{Name: "db", Condition: DependencyReady}}})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	for m.Get("db").IsRunning() {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// db was ready once: its exit must not let api start again against it
+	pid := m.Get("api").GetPID()
+	err := m.Restart("api")
+	if err == nil || !strings.Contains(err.Error(), "dependency db exited") {
+		t.Errorf("Expected the exited dependency to fail the restart, got %v", err)
+	}
+	if m.Get("api").GetPID() != pid {
+		t.Error("api should not be started again without its dependency")
+	}
+}
+
+func TestDependencies_CascadingRestart(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	m := NewManager(nil)
+	m.Add("db", &Config{ExecProgramPath: execPath})
+	m.Add("api", &Config{ExecProgramPath: execPath, DependsOn: []Dependency{{Name: "db", Condition: DependencyReady}}})
+	m.Add("tool", &Config{ExecProgramPath: execPath})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	before := map[string]int{}
+	for _, s := range m.Status() {
+		before[s.Name] = s.PID
+	}
+
+	if err := m.Restart("db"); err != nil {
+		t.Fatalf("Restart() failed: %v", err)
+	}
+
+	for _, s := range m.Status() {
+		restarted := s.PID != before[s.Name]
+		if !s.Running {
+			t.Errorf("%s should be running after restart", s.Name)
+		}
+		if s.Name == "tool" && restarted {
+			t.Error("tool does not depend on db and should not restart")
+		}
+		if s.Name != "tool" && !restarted {
+			t.Errorf("%s should have been restarted", s.Name)
+		}
+	}
+}
+
+func TestDependencies_CascadingAutoRestart(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	m := NewManager(nil)
+	m.Add("db", &Config{ExecProgramPath: execPath, RestartPolicy: RestartAlways, RestartDelay: 100 * time.Millisecond})
+	m.Add("api", &Config{ExecProgramPath: execPath, DependsOn: []Dependency{{Name: "db", Condition: DependencyReady}}})
+	m.Add("tool", &Config{ExecProgramPath: execPath})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	apiPID, toolPID := m.Get("api").GetPID(), m.Get("tool").GetPID()
+
+	// db crashes and its RestartPolicy starts it again
+	if err := m.Get("db").ActiveProcess().Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for m.Get("api").GetPID() == apiPID || !m.Get("api").IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("api was not restarted after db restarted")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if restarts := m.Get("db").Restarts(); restarts != 1 {
+		t.Errorf("Expected db to be restarted once, got %d", restarts)
+	}
+	if m.Get("tool").GetPID() != toolPID {
+		t.Error("tool does not depend on db and should not restart")
+	}
+}
+
+func TestDependencies_StopAllDuringCascadingRestart(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	// The restart of api waits for db to be ready while StopAll stops api
+	slowReady := func(int) error { time.Sleep(300 * time.Millisecond); return nil }
+	m := NewManager(nil)
+	m.Add("db", &Config{ExecProgramPath: execPath, ReadinessProbe: slowReady})
+	m.Add("api", &Config{ExecProgramPath: execPath, DependsOn: []Dependency{{Name: "db", Condition: DependencyReady}}})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	// Restarted outside the Manager: api restarts in the background
+	restarted := make(chan error, 1)
+	go func() { restarted <- m.Get("db").RunProgram() }()
+	time.Sleep(100 * time.Millisecond)
+	if err := m.StopAll(); err != nil {
+		t.Fatalf("StopAll() failed: %v", err)
+	}
+	if err := <-restarted; err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	for _, s := range m.Status() {
+		if s.Running {
+			t.Errorf("%s started again after StopAll", s.Name)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package gorun
+
+import "time"
+
+// EventType identifies a lifecycle notification sent to Config.OnEvent
+type EventType int
+
+const (
+	EventStarted EventType = iota // The process was started
+	EventReady                    // The process passed its ReadinessProbe (or started without one) and is active
+	EventExited                   // The process was reaped
+	EventCrash                    // The process wrote a panic, fatal error or data race report
+	EventRace                     // The process reported a data race not seen before in the session
+	EventGaveUp                   // The RestartPolicy stopped restarting the program after MaxRestartAttempts failed attempts
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventStarted:
+		return "started"
+	case EventReady:
+		return "ready"
+	case EventExited:
+		return "exited"
+	case EventCrash:
+		return "crash"
+	case EventRace:
+		return "race"
+	case EventGaveUp:
+		return "gave-up"
+	}
+	return "unknown"
+}
+
+// Event is a lifecycle notification about one process started by GoRun
+type Event struct {
+	Type  EventType
+	PID   int
+	Time  time.Time
+	Exit  *ExitInfo   // EventExited only
+	Crash *Crash      // EventCrash only, sent before EventExited for a panic or fatal error
+	Race  *RaceReport // EventRace only, after the EventCrash of its first report
+	Err   error       // EventGaveUp only: why the last restart attempt failed
+}
+
+// emit sends ev to Config.OnEvent if set
+func (h *GoRun) emit(ev Event) {
+	if h.OnEvent == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	h.OnEvent(ev)
+}
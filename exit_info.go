@@ -0,0 +1,178 @@
+package gorun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ExitInfo describes how a process started by GoRun ended
+type ExitInfo struct {
+	PID       int
+	Code      int       // Exit code, -1 if the process was terminated by a signal
+	Signal    os.Signal // Signal that terminated the process, nil otherwise
+	Requested bool      // The exit followed StopProgram or a restart, it was expected
+	StartTime time.Time
+	ExitTime  time.Time
+	Err       error  // Error returned by Wait, if any
+	Limit     string // Config.Limits entry that killed the process, eg: LimitCPUTime
+	Crash     *Crash // Panic or fatal error it wrote, or its last data race if it failed
+}
+
+// Success reports whether the process exited with code 0
+func (e *ExitInfo) Success() bool {
+	return e.Code == 0
+}
+
+func (e *ExitInfo) String() string {
+	if e.Crash != nil {
+		return fmt.Sprintf("%s after %s", e.exitString(), e.Crash)
+	}
+	return e.exitString()
+}
+
+func (e *ExitInfo) exitString() string {
+	if e.Signal != nil && e.Limit != "" {
+		return fmt.Sprintf("process %d terminated by signal %v (%s exceeded)", e.PID, e.Signal, e.Limit)
+	}
+	if e.Signal != nil {
+		return fmt.Sprintf("process %d terminated by signal %v", e.PID, e.Signal)
+	}
+	if e.Err == errAttachedExit {
+		return fmt.Sprintf("process %d exited, %v", e.PID, e.Err)
+	}
+	return fmt.Sprintf("process %d exited with code %d", e.PID, e.Code)
+}
+
+// newExitInfo builds the ExitInfo of a reaped cmd
+func newExitInfo(cmd *exec.Cmd, startTime time.Time, waitErr error, requested bool) *ExitInfo {
+	info := &ExitInfo{
+		PID:       cmd.Process.Pid,
+		Code:      cmd.ProcessState.ExitCode(),
+		Requested: requested,
+		StartTime: startTime,
+		ExitTime:  time.Now(),
+		Err:       waitErr,
+	}
+
+	if cmd.ProcessState != nil {
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			info.Signal = status.Signal()
+		}
+	}
+	return info
+}
+
+// processExit is where the one goroutine waiting for a process publishes how it
+// ended. Everything else observes done instead of waiting for the process.
+type processExit struct {
+	done    chan struct{} // Closed once info is set
+	info    *ExitInfo
+	applied bool // The GoRun state was updated from info, guarded by GoRun.mutex
+}
+
+func newProcessExit() *processExit {
+	return &processExit{done: make(chan struct{})}
+}
+
+// publish sets info and closes done, called once by the waiter
+func (e *processExit) publish(info *ExitInfo) {
+	e.info = info
+	close(e.done)
+}
+
+// published reports whether the process has been reaped and its exit published
+func (e *processExit) published() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyExitUnsafe updates the state from the published exit of cmd if cmd is still
+// the active instance, once: by its waiter or by StopProgram, whichever holds the
+// mutex first. It reports whether it did.
+// Should only be called when mutex is already held
+func (h *GoRun) applyExitUnsafe(cmd *exec.Cmd, exit *processExit) bool {
+	if exit.applied || h.Cmd != cmd || !exit.published() {
+		return false
+	}
+	exit.applied = true
+	h.ready.set(false)
+	h.isRunning = false
+	h.lastExit = exit.info
+	return true
+}
+
+// LastExit returns how the last active process ended, or nil if none has exited yet
+func (h *GoRun) LastExit() *ExitInfo {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.lastExit == nil {
+		return nil
+	}
+	info := *h.lastExit
+	return &info
+}
+
+// exitInfoJSON is the wire form of ExitInfo: signal number and error text
+type exitInfoJSON struct {
+	PID       int       `json:"pid"`
+	Code      int       `json:"code"`
+	Signal    int       `json:"signal,omitempty"`
+	Requested bool      `json:"requested,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	ExitTime  time.Time `json:"exit_time"`
+	Err       string    `json:"error,omitempty"`
+	Limit     string    `json:"limit,omitempty"`
+	Crash     *Crash    `json:"crash,omitempty"`
+}
+
+func (e *ExitInfo) MarshalJSON() ([]byte, error) {
+	v := exitInfoJSON{
+		PID:       e.PID,
+		Code:      e.Code,
+		Requested: e.Requested,
+		StartTime: e.StartTime,
+		ExitTime:  e.ExitTime,
+		Limit:     e.Limit,
+		Crash:     e.Crash,
+	}
+	if sig, ok := e.Signal.(syscall.Signal); ok {
+		v.Signal = int(sig)
+	}
+	if e.Err != nil {
+		v.Err = e.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+func (e *ExitInfo) UnmarshalJSON(data []byte) error {
+	var v exitInfoJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = ExitInfo{
+		PID:       v.PID,
+		Code:      v.Code,
+		Requested: v.Requested,
+		StartTime: v.StartTime,
+		ExitTime:  v.ExitTime,
+		Limit:     v.Limit,
+		Crash:     v.Crash,
+	}
+	if v.Signal != 0 {
+		e.Signal = syscall.Signal(v.Signal)
+	}
+	if v.Err != "" {
+		e.Err = errors.New(v.Err)
+	}
+	return nil
+}
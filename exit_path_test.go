@@ -0,0 +1,201 @@
+package gorun
+
+import (
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stressCycles returns the number of run/stop cycles of the stress tests
+func stressCycles() int {
+	if testing.Short() {
+		return 200
+	}
+	return 2000
+}
+
+// requireSleep skips the test without a sleep command
+func requireSleep(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+}
+
+// exitCounter checks that every started process is reported exited exactly once
+type exitCounter struct {
+	mu      sync.Mutex
+	started map[int]int
+	exited  map[int]int
+}
+
+func newExitCounter() *exitCounter {
+	return &exitCounter{started: map[int]int{}, exited: map[int]int{}}
+}
+
+func (c *exitCounter) onEvent(ev Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch ev.Type {
+	case EventStarted:
+		c.started[ev.PID]++
+	case EventExited:
+		c.exited[ev.PID]++
+	}
+}
+
+func (c *exitCounter) check(t *testing.T) {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.started) == 0 {
+		t.Fatal("No process was started")
+	}
+	for pid, n := range c.started {
+		// A PID may be reused by a later process of the test
+		if c.exited[pid] != n {
+			t.Errorf("Process %d started %d times but reported exited %d times", pid, n, c.exited[pid])
+		}
+	}
+}
+
+func TestExitPath_RunStopConcurrently(t *testing.T) {
+	requireSleep(t)
+	const workers = 8
+	cycles := stressCycles() / workers
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events := newExitCounter()
+			gr := New(&Config{
+				ExecProgramPath: "sleep",
+				RunArguments:    func() []string { return []string{"30"} },
+				ExitChan:        make(chan bool),
+				OnEvent:         events.onEvent,
+			})
+			defer gr.StopProgram()
+
+			// Readers racing with the run/stop cycles
+			stop := make(chan struct{})
+			var readers sync.WaitGroup
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						gr.Status()
+						gr.IsRunning()
+						gr.GetPID()
+						gr.LastExit()
+					}
+				}
+			}()
+
+			for i := 0; i < cycles; i++ {
+				if err := gr.RunProgram(); err != nil {
+					t.Errorf("RunProgram() failed: %v", err)
+					break
+				}
+				pid := gr.GetPID()
+				if err := gr.StopProgram(); err != nil {
+					t.Errorf("StopProgram() failed: %v", err)
+					break
+				}
+				// The exit is recorded by the time StopProgram returns
+				if exit := gr.LastExit(); exit == nil || exit.PID != pid || !exit.Requested {
+					t.Errorf("Cycle %d: expected the requested exit of %d, got %v", i, pid, exit)
+					break
+				}
+				if gr.IsRunning() {
+					t.Errorf("Cycle %d: still running after StopProgram()", i)
+					break
+				}
+			}
+			close(stop)
+			readers.Wait()
+			events.check(t)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestExitPath_SharedGoRun(t *testing.T) {
+	requireSleep(t)
+	events := newExitCounter()
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"30"} },
+		ExitChan:        make(chan bool),
+		OnEvent:         events.onEvent,
+	})
+
+	// Every goroutine restarts and stops the same program
+	const workers = 8
+	cycles := stressCycles() / workers / 2
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < cycles; i++ {
+				var err error
+				if (i+w)%2 == 0 {
+					err = gr.RunProgram()
+				} else {
+					err = gr.StopProgram()
+				}
+				if err != nil && failures.Add(1) == 1 {
+					t.Errorf("Cycle %d: %v", i, err)
+				}
+				gr.Status()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if gr.IsRunning() || gr.LastExit() == nil {
+		t.Errorf("Expected a stopped program with its last exit, running=%v exit=%v", gr.IsRunning(), gr.LastExit())
+	}
+	events.check(t)
+}
+
+func TestExitPath_ExitRacingStop(t *testing.T) {
+	requireSleep(t)
+	events := newExitCounter()
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		// Exits on its own right when it is being stopped
+		RunArguments: func() []string { return []string{"0.001"} },
+		ExitChan:     make(chan bool),
+		OnEvent:      events.onEvent,
+	})
+
+	for i := 0; i < stressCycles()/4; i++ {
+		if err := gr.RunProgram(); err != nil {
+			t.Fatalf("RunProgram() failed: %v", err)
+		}
+		pid := gr.GetPID()
+		if i%2 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := gr.StopProgram(); err != nil {
+			t.Fatalf("StopProgram() failed: %v", err)
+		}
+		if exit := gr.LastExit(); exit == nil || exit.PID != pid {
+			t.Fatalf("Cycle %d: expected the exit of %d, got %v", i, pid, exit)
+		}
+	}
+	events.check(t)
+}
@@ -1,8 +1,11 @@
 package gorun
 
 import (
+	"os"
 	"os/exec"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Config struct {
@@ -10,17 +13,112 @@ type Config struct {
 	RunArguments    func() []string // eg: []string{"dev"}
 	ExitChan        chan bool
 	Logger          func(message ...any)
-	KillAllOnStop   bool   // If true, kills all instances of the executable when stopping
-	WorkingDir      string // eg: "/path/to/working/dir"
+	KillAllOnStop   bool          // If true, kills all instances of the executable when stopping
+	WorkingDir      string        // eg: "/path/to/working/dir"
+	StopTimeout     time.Duration // Time allowed for a graceful stop before killing (default: 3s)
+	StopSignal      os.Signal     // Signal sent for a graceful stop (default: SIGTERM, Windows always kills)
+
+	RestartPolicy RestartPolicy // Restart the program when it exits unexpectedly (default: RestartNever)
+	RestartDelay  time.Duration // Wait before an automatic restart (default: 1s), doubled after each failed attempt up to 30s
+
+	MaxRestartAttempts int // Optional: give up, sending EventGaveUp, after this many automatic restarts in a row failing to start (default: retry until stopped)
+
+	RestartStrategy  RestartStrategy     // How RunProgram replaces a running program (default: RestartStopFirst)
+	ReadinessProbe   func(pid int) error // Optional: returns nil once the started program is ready, eg: dial its port
+	ReadinessTimeout time.Duration       // Max time to wait for ReadinessProbe (default: 10s)
+
+	Proxy *ProxyConfig // Optional reverse proxy in front of the program, see ProxyConfig
+
+	Ports           []int         // Optional: ports freed before each start, eg: []int{8080} (ignored by RestartBlueGreen)
+	PortWaitTimeout time.Duration // Max time to wait for a port to be released (default: 5s)
+
+	// Optional: names of free localhost ports allocated for the program, eg: []string{"http"}.
+	// RunArguments output, Env and Proxy.TargetAddr may reference them as {{port "http"}}.
+	PortNames   []string
+	StickyPorts bool     // Keep the allocated ports across restarts (RestartBlueGreen always allocates new ones)
+	Env         []string // Optional: extra environment for the program, eg: []string{"PORT={{port \"http\"}}"}
+
+	// Optional: lifecycle notifications. Called synchronously from gorun internals,
+	// so it must return quickly and must not call methods of this GoRun.
+	OnEvent func(Event)
+
+	DependsOn []Dependency // Used by Manager: programs that must reach a condition before this one starts
+
+	StatsProcessTree bool // Stats and WatchStats sum the resource usage of every descendant of the program
+
+	Limits *Limits       // Optional: resource limits applied to the program (Linux), requires Init
+	Cgroup *CgroupConfig // Optional: run every process in its own cgroup v2 (Linux), see CgroupConfig
+
+	// Optional: identity of the program (Linux), names or numeric ids. Group defaults to the
+	// primary group of User and Groups to the groups User is a member of.
+	User        string
+	Group       string
+	Groups      []string // Supplementary groups
+	NoNewPrivs  bool     // Set no_new_privs: setuid binaries and file capabilities grant nothing
+	AmbientCaps []string // Capabilities kept by the program, eg: []string{"CAP_NET_BIND_SERVICE"}
+
+	Sandbox *SandboxConfig // Optional: run the program in new namespaces (Linux), requires Init, see SandboxConfig
+
+	// Optional: signal the program and all its descendants receive when gorun dies, even
+	// from SIGKILL (Linux), eg: syscall.SIGKILL. Without Sandbox, the program runs under
+	// a small init process, a subreaper adopting its orphans, as with Sandbox where the
+	// whole sandbox dies with it. GetPID, Signal and forced stops reach the program itself.
+	// Requires Init.
+	ParentDeathSignal os.Signal
+	// Start the program in its own process group (Linux): stop signals and forced
+	// kills reach its background children, which are killed when the program exits
+	ProcessGroup bool
+
+	// Optional: file recording the running instances, eg: ".gorun/api.json". The first
+	// RunProgram stops the instances a crashed gorun left behind, verified by PID,
+	// start time, executable and arguments (Linux).
+	StateFile string
+
+	Coverage *CoverageConfig // Optional: collect the coverage of a program built with -cover, see CoverageConfig
+
+	Debug *DebugConfig // Optional: run the program under a headless Delve server, see DebugConfig
 }
 
 type GoRun struct {
 	*Config
 	Cmd        *exec.Cmd
 	isRunning  bool
-	hasWaited  bool         // Track if Wait() has been called
-	mutex      sync.RWMutex // Protect concurrent access to running state
-	safeBuffer *SafeBuffer  // Thread-safe buffer for Logger
+	mutex      sync.RWMutex  // Protect concurrent access to running state
+	lifecycle  sync.Mutex    // Serializes starts and stops, taken before mutex: a start releases mutex while waiting for readiness
+	safeBuffer *SafeBuffer   // Thread-safe buffer for Logger
+	exit       *processExit  // Published by the one waiter of the current Cmd
+	ready      *readyGate    // Open while the current Cmd is ready to receive traffic
+	proxy      *reverseProxy // Optional reverse proxy, nil if Config.Proxy is nil
+
+	stopRequested atomic.Bool // StopProgram was called since the last start, exits are expected
+	restarts      int         // Automatic restarts done by the RestartPolicy
+	generation    uint64      // Incremented by RunProgram, StopProgram and DumpGoroutines: a pending automatic restart gives up
+	lastExit      *ExitInfo   // How the last active process ended
+	startTime     time.Time   // When the active process was started
+	attached      bool        // The active process was attached by Attach, not started
+
+	statsSampler statsSampler // Previous sample of Stats, for CPUPercent
+	races        raceLog      // Distinct data races reported across restarts
+
+	coverageMutex   sync.Mutex // Serializes the updates of Coverage.Dir
+	coveragePending int        // Runs that exited and are not merged yet, guarded by coverageMutex
+	coverageMerged  *sync.Cond // Broadcast when a pending run was merged
+
+	debugAddr string       // Debug server address allocated for DebugConfig, guarded by mutex
+	debug     debugTargets // Programs started by every live dlv
+
+	cgroupsMutex sync.Mutex
+	cgroups      map[int]*cgroup // cgroup of every live instance started in one
+
+	sandboxesMutex sync.Mutex
+	sandboxes      map[int]*sandbox // Init process of every live instance started in a sandbox or subreaper
+
+	stateFileMutex sync.Mutex // Serializes the updates of Config.StateFile
+	orphansChecked bool       // The StateFile was checked for orphans, guarded by mutex
+
+	portsMutex sync.RWMutex           // Protects ports, readable while RunProgram holds mutex
+	ports      map[string]int         // Ports allocated for the active (or last started) instance
+	portsByPID map[int]map[string]int // Ports of every live instance, eg: one being probed
 }
 
 func New(c *Config) *GoRun {
@@ -32,14 +130,21 @@ func New(c *Config) *GoRun {
 		buffer = NewSafeBuffer()
 	}
 
-	return &GoRun{
+	h := &GoRun{
 		Config:     c,
 		Cmd:        &exec.Cmd{},
 		isRunning:  false,
-		hasWaited:  false,
 		mutex:      sync.RWMutex{},
 		safeBuffer: buffer,
+		ready:      newReadyGate(),
 	}
+	h.coverageMerged = sync.NewCond(&h.coverageMutex)
+
+	if c.Proxy != nil {
+		h.proxy = newReverseProxy(c.Proxy, h.ready, h.proxyTarget)
+	}
+
+	return h
 }
 
 // getOutput returns the captured output in a thread-safe manner (unexported)
@@ -0,0 +1,165 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// GoroutineDump is the stack dump a Go program writes to stderr on SIGQUIT
+type GoroutineDump struct {
+	PID        int         `json:"pid"`
+	Time       time.Time   `json:"time"`
+	Goroutines []Goroutine `json:"goroutines"`
+	Output     string      `json:"output"` // Everything the program wrote from the signal to its exit
+	Exit       *ExitInfo   `json:"exit,omitempty"`
+}
+
+// Goroutine is one goroutine of a GoroutineDump
+type Goroutine struct {
+	ID        int          `json:"id"`
+	State     string       `json:"state"` // eg: "running", "chan receive, 2 minutes", or the access of a data race: "Previous write"
+	Frames    []StackFrame `json:"frames"`
+	CreatedBy *StackFrame  `json:"created_by,omitempty"`
+}
+
+// StackFrame is a function call of a goroutine stack
+type StackFrame struct {
+	Function string `json:"function"` // eg: "main.(*server).serve"
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// goroutineHeader matches "goroutine 1 [running]:", with the gp/m details of GOTRACEBACK=system too
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[([^\]]*)\]:$`)
+
+// DumpGoroutines sends SIGQUIT to the program, a Go program writes the stacks of
+// all its goroutines and exits. It returns them once the program has exited, the
+// exit is recorded as requested. With restart the program is started again.
+// Only the program gets the signal, not its process group.
+func (h *GoRun) DumpGoroutines(restart bool) (*GoroutineDump, error) {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.Debug != nil {
+		return nil, ErrDebugMode
+	}
+	if !h.isRunning || h.Cmd == nil || h.Cmd.Process == nil || h.exit == nil || h.exit.published() {
+		return nil, ErrNotRunning
+	}
+	if h.attached {
+		return nil, errors.New("the output of an attached process is not captured")
+	}
+
+	cmd, exit := h.Cmd, h.exit
+	pid := h.programPID(cmd.Process.Pid)
+	offset := h.safeBuffer.Len()
+
+	h.generation++
+	h.ready.set(false)
+	h.stopRequested.Store(true)
+	dump := &GoroutineDump{PID: pid, Time: time.Now()}
+	if err := cmd.Process.Signal(syscall.SIGQUIT); err != nil {
+		h.stopRequested.Store(false)
+		h.ready.set(true)
+		return nil, fmt.Errorf("dump goroutines of program %d: %w", pid, err)
+	}
+
+	// The output is fully copied once the exit is published
+	var err error
+	select {
+	case <-exit.done:
+	case <-time.After(h.stopTimeout()):
+		err = fmt.Errorf("program %d did not exit on SIGQUIT, it was stopped", pid)
+		if stopErr := h.terminateProcess(cmd.Process, exit.done); stopErr != nil {
+			return nil, stopErr
+		}
+	}
+	if !h.applyExitUnsafe(cmd, exit) {
+		h.isRunning = false
+	}
+	dump.Exit = exit.info
+
+	if output := h.safeBuffer.String(); offset <= len(output) {
+		dump.Output = output[offset:]
+	}
+	dump.Goroutines = parseGoroutines(dump.Output)
+	if err == nil && len(dump.Goroutines) == 0 {
+		err = fmt.Errorf("program %d wrote no goroutine dump", pid)
+	}
+
+	if restart {
+		if runErr := h.runProgramUnsafe(); runErr != nil {
+			return dump, errors.Join(err, fmt.Errorf("restart after goroutine dump: %w", runErr))
+		}
+	}
+	return dump, err
+}
+
+// parseGoroutines extracts the goroutines of a Go stack dump, other output is skipped
+func parseGoroutines(output string) []Goroutine {
+	var goroutines []Goroutine
+	var current *Goroutine
+	var frame *StackFrame
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if current == nil {
+			m := goroutineHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			id, _ := strconv.Atoi(m[1])
+			goroutines = append(goroutines, Goroutine{ID: id, State: m[2]})
+			current = &goroutines[len(goroutines)-1]
+			continue
+		}
+
+		switch {
+		case line == "":
+			// A blank line ends the goroutine
+			current, frame = nil, nil
+		case strings.HasPrefix(line, "\t"):
+			// Location of the previous function line
+			if frame != nil {
+				frame.File, frame.Line = parseFrameLocation(line)
+				frame = nil
+			}
+		case strings.HasPrefix(line, "created by "):
+			function, _, _ := strings.Cut(strings.TrimPrefix(line, "created by "), " in goroutine ")
+			current.CreatedBy = &StackFrame{Function: function}
+			frame = current.CreatedBy
+		case strings.HasPrefix(line, "..."):
+			// "...additional frames elided..."
+			frame = nil
+		default:
+			function := line
+			if i := strings.LastIndexByte(function, '('); i > 0 {
+				function = function[:i]
+			}
+			current.Frames = append(current.Frames, StackFrame{Function: function})
+			frame = &current.Frames[len(current.Frames)-1]
+		}
+	}
+	return goroutines
+}
+
+// parseFrameLocation parses "\t/path/to/file.go:42 +0x1d"
+func parseFrameLocation(line string) (string, int) {
+	location, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	i := strings.LastIndexByte(location, ':')
+	if i < 0 {
+		return location, 0
+	}
+	n, err := strconv.Atoi(location[i+1:])
+	if err != nil {
+		return location, 0
+	}
+	return location[:i], n
+}
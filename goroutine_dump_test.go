@@ -0,0 +1,161 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGoroutines(t *testing.T) {
+	output := `starting
+SIGQUIT: quit
+PC=0x46e3a1 m=0 sigcode=0
+
+goroutine 1 gp=0xc000002380 m=nil [sleep]:
+time.Sleep(0xdf8475800)
+	/usr/local/go/src/runtime/time.go:338 +0x165
+main.main()
+	/src/app/main.go:28 +0x65
+
+goroutine 6 [chan receive, 2 minutes]:
+main.(*server).wait(0xc000010000, 0x1)
+	/src/app/server.go:12 +0x25
+...additional frames elided...
+created by main.main in goroutine 1
+	/src/app/main.go:17 +0x3e
+
+rax    0xfffffffffffffffc
+App: ./app closed with error: exit status 2
+`
+	goroutines := parseGoroutines(output)
+	if len(goroutines) != 2 {
+		t.Fatalf("Expected 2 goroutines, got %+v", goroutines)
+	}
+
+	g := goroutines[0]
+	if g.ID != 1 || g.State != "sleep" || len(g.Frames) != 2 || g.CreatedBy != nil {
+		t.Errorf("Unexpected first goroutine: %+v", g)
+	}
+	if f := g.Frames[1]; f.Function != "main.main" || f.File != "/src/app/main.go" || f.Line != 28 {
+		t.Errorf("Unexpected frame: %+v", f)
+	}
+
+	g = goroutines[1]
+	if g.ID != 6 || g.State != "chan receive, 2 minutes" || len(g.Frames) != 1 || g.Frames[0].Function != "main.(*server).wait" {
+		t.Errorf("Unexpected second goroutine: %+v", g)
+	}
+	if g.CreatedBy == nil || *g.CreatedBy != (StackFrame{Function: "main.main", File: "/src/app/main.go", Line: 17}) {
+		t.Errorf("Unexpected created by: %+v", g.CreatedBy)
+	}
+}
+
+// startGoroutinesProgram runs goroutines_program until it reports having started
+func startGoroutinesProgram(t *testing.T, config *Config) *GoRun {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGQUIT is not available on Windows")
+	}
+
+	execPath := buildTestProgram(t, "goroutines_program")
+	t.Cleanup(func() { os.Remove(execPath) })
+
+	config.ExecProgramPath = execPath
+	config.ExitChan = make(chan bool)
+	gr := New(config)
+	t.Cleanup(func() { gr.StopProgram() })
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(gr.getOutput(), "GOROUTINES_PROGRAM_STARTED"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Program did not start: %q", gr.getOutput())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return gr
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	gr := startGoroutinesProgram(t, &Config{RestartPolicy: RestartAlways})
+	pid := gr.GetPID()
+
+	dump, err := gr.DumpGoroutines(false)
+	if err != nil {
+		t.Fatalf("DumpGoroutines() failed: %v", err)
+	}
+	if dump.PID != pid || !strings.Contains(dump.Output, "SIGQUIT: quit") {
+		t.Errorf("Unexpected dump of %d: %+v", pid, dump)
+	}
+
+	var waiting *Goroutine
+	var frame StackFrame
+	for i, g := range dump.Goroutines {
+		for _, f := range g.Frames {
+			if f.Function == "main.waitForever" {
+				waiting, frame = &dump.Goroutines[i], f
+			}
+		}
+	}
+	if waiting == nil || waiting.State != "chan receive" || waiting.CreatedBy == nil || waiting.CreatedBy.Function != "main.main" {
+		t.Fatalf("Expected the goroutine of waitForever, got %+v", dump.Goroutines)
+	}
+	if !strings.HasSuffix(frame.File, "goroutines_program.go") || frame.Line == 0 {
+		t.Errorf("Unexpected frame location: %+v", frame)
+	}
+
+	// The exit is requested: no restart despite RestartAlways
+	if exit := gr.LastExit(); exit == nil || dump.Exit == nil || exit.PID != pid || !exit.Requested {
+		t.Errorf("Expected the requested exit in LastExit, got %v", exit)
+	}
+	time.Sleep(defaultRestartDelay + 200*time.Millisecond)
+	if gr.IsRunning() {
+		t.Error("Program should stay stopped after the dump")
+	}
+
+	if _, err := gr.DumpGoroutines(false); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestDumpGoroutines_Restart(t *testing.T) {
+	gr := startGoroutinesProgram(t, &Config{})
+	pid := gr.GetPID()
+
+	dump, err := gr.DumpGoroutines(true)
+	if err != nil {
+		t.Fatalf("DumpGoroutines() failed: %v", err)
+	}
+	if len(dump.Goroutines) == 0 {
+		t.Error("Expected goroutines in the dump")
+	}
+	if !gr.IsRunning() || gr.GetPID() == pid {
+		t.Errorf("Expected a new instance, running=%v pid=%d", gr.IsRunning(), gr.GetPID())
+	}
+}
+
+func TestDumpGoroutines_NotGo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGQUIT is not available on Windows")
+	}
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", "exec sleep 30"} },
+		ExitChan:        make(chan bool),
+	})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	dump, err := gr.DumpGoroutines(false)
+	if err == nil || !strings.Contains(err.Error(), "no goroutine dump") {
+		t.Errorf("Expected an error without dump, got %v", err)
+	}
+	if dump == nil || dump.Exit == nil || gr.IsRunning() {
+		t.Errorf("Expected the exit of the program, got %+v", dump)
+	}
+}
@@ -13,6 +13,12 @@ import (
 	"time"
 )
 
+// TestMain lets the test binary act as the helper processes of gorun
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
 // Helper function to build test programs
 func buildTestProgram(t *testing.T, programName string) string {
 	t.Helper()
@@ -0,0 +1,12 @@
+package gorun
+
+// Init must be called first thing in main by programs using Config.Sandbox,
+// Config.ParentDeathSignal or Config.Limits (Linux). gorun starts its own executable
+// as a helper process, the init process of a sandbox, the subreaper signaling the
+// program tree or the gate holding the program until its limits are applied: Init
+// turns it into that helper and never returns then. Otherwise Init opens the
+// executable the helpers are started from, so it may be rebuilt or removed on
+// disk meanwhile, and returns right away.
+func Init() {
+	initHelper()
+}
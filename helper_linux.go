@@ -0,0 +1,56 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// oPath is O_PATH, missing from syscall
+const oPath = 0x200000
+
+// helperExeFD is the descriptor of gorun's own executable in a helper process
+const helperExeFD = 5
+
+// helperPath executes the helper from its descriptor, whatever is on disk by now
+var helperPath = fmt.Sprintf("/proc/self/fd/%d", helperExeFD)
+
+var (
+	initOnce sync.Once
+	selfFile *os.File // gorun's own executable, opened by Init
+	selfErr  error    // Why it couldn't be opened
+)
+
+func initHelper() {
+	// gorun's own executable was started as a helper process
+	if spec := os.Getenv(sandboxEnv); spec != "" {
+		syscall.CloseOnExec(helperExeFD)
+		os.Exit(sandboxInit(spec))
+	}
+	if data := os.Getenv(limitsExecEnv); data != "" {
+		syscall.CloseOnExec(helperExeFD)
+		os.Exit(limitsExec(data))
+	}
+
+	initOnce.Do(func() {
+		// A path only: executing the helper needs no read permission
+		fd, err := syscall.Open("/proc/self/exe", oPath|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			selfErr = fmt.Errorf("opening /proc/self/exe: %w", err)
+			return
+		}
+		selfFile = os.NewFile(uintptr(fd), "/proc/self/exe")
+	})
+}
+
+// selfExecutable returns gorun's own executable to start a helper process for feature
+func selfExecutable(feature string) (*os.File, error) {
+	if selfFile == nil && selfErr == nil {
+		return nil, fmt.Errorf("%s requires calling gorun.Init() first thing in main", feature)
+	}
+	if selfErr != nil {
+		return nil, fmt.Errorf("%s: gorun's own executable is not usable: %w", feature, selfErr)
+	}
+	return selfFile, nil
+}
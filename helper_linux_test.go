@@ -0,0 +1,33 @@
+package gorun
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestInit_RequiredByHelpers(t *testing.T) {
+	file, err := selfFile, selfErr
+	selfFile, selfErr = nil, nil
+	defer func() { selfFile, selfErr = file, err }()
+
+	for feature, config := range map[string]*Config{
+		"Config.Sandbox":           {Sandbox: &SandboxConfig{}},
+		"Config.ParentDeathSignal": {ParentDeathSignal: syscall.SIGKILL},
+		"Config.Limits":            {Limits: &Limits{OpenFiles: 64}},
+	} {
+		config.ExecProgramPath = "sleep"
+		config.RunArguments = func() []string { return []string{"5"} }
+		config.ExitChan = make(chan bool)
+		gr := New(config)
+
+		err := gr.RunProgram()
+		if err == nil || !strings.Contains(err.Error(), feature+" requires calling gorun.Init()") {
+			t.Errorf("Expected %s to require Init, got %v", feature, err)
+		}
+		if gr.IsRunning() {
+			gr.StopProgram()
+			t.Errorf("Program should not run with %s without Init", feature)
+		}
+	}
+}
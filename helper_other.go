@@ -0,0 +1,6 @@
+//go:build !linux
+
+package gorun
+
+// initHelper has nothing to do: the helper processes are Linux only
+func initHelper() {}
@@ -0,0 +1,68 @@
+package gorun
+
+import (
+	"errors"
+	"time"
+)
+
+// Limits are resource limits (setrlimit) applied to the program when it starts.
+// Zero fields are left unlimited, or as inherited from gorun. Linux only.
+type Limits struct {
+	AddressSpace uint64        // RLIMIT_AS: max virtual memory in bytes, allocations fail beyond it
+	OpenFiles    uint64        // RLIMIT_NOFILE: max open file descriptors
+	CPUTime      time.Duration // RLIMIT_CPU: max CPU time, rounded up to seconds. SIGXCPU, then SIGKILL 1s later
+	Processes    uint64        // RLIMIT_NPROC: max processes of the user running the program, theirs included
+	CoreSize     uint64        // RLIMIT_CORE: max core dump size in bytes
+	NoCoreDumps  bool          // RLIMIT_CORE set to 0, overrides CoreSize
+}
+
+// Names of the limits, used in errors and ExitInfo.Limit
+const (
+	LimitAddressSpace = "RLIMIT_AS"
+	LimitOpenFiles    = "RLIMIT_NOFILE"
+	LimitCPUTime      = "RLIMIT_CPU"
+	LimitProcesses    = "RLIMIT_NPROC"
+	LimitCoreSize     = "RLIMIT_CORE"
+)
+
+// rlimit is one limit to apply, soft and hard values
+type rlimit struct {
+	name       string
+	soft, hard uint64
+}
+
+// rlimits returns the limits to apply, in a stable order
+func (l *Limits) rlimits() []rlimit {
+	if l == nil {
+		return nil
+	}
+
+	var limits []rlimit
+	add := func(name string, value uint64) {
+		if value > 0 {
+			limits = append(limits, rlimit{name, value, value})
+		}
+	}
+	add(LimitAddressSpace, l.AddressSpace)
+	add(LimitOpenFiles, l.OpenFiles)
+	if l.CPUTime > 0 {
+		// A soft limit below the hard one makes the kernel send SIGXCPU before SIGKILL
+		seconds := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		limits = append(limits, rlimit{LimitCPUTime, seconds, seconds + 1})
+	}
+	add(LimitProcesses, l.Processes)
+	if l.NoCoreDumps {
+		limits = append(limits, rlimit{LimitCoreSize, 0, 0})
+	} else {
+		add(LimitCoreSize, l.CoreSize)
+	}
+	return limits
+}
+
+// validate reports limits that can't be applied on this platform before starting
+func (l *Limits) validate() error {
+	if len(l.rlimits()) > 0 && !limitsSupported {
+		return errors.New("resource limits are only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package gorun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const limitsSupported = true
+
+// limitsExecEnv carries the command the limits gate executes, see setupLimitsGate
+const limitsExecEnv = "GORUN_LIMITS_EXEC"
+
+// File descriptors of the limits gate: the parent writes one byte to limitsSyncFD once
+// the limits are applied, limitsStatusFD is closed by the exec or reports its error
+const (
+	limitsSyncFD   = 3
+	limitsStatusFD = 4
+)
+
+// limitsExecTimeout bounds the time the limits gate takes to execute the program
+const limitsExecTimeout = 10 * time.Second
+
+// limitsExecSpec is the command the limits gate executes
+type limitsExecSpec struct {
+	Path        string             `json:"path"`
+	Args        []string           `json:"args"`
+	Credential  *sandboxCredential `json:"credential,omitempty"`
+	AmbientCaps []uintptr          `json:"ambient_caps,omitempty"`
+}
+
+// limitsGate is the parent side of a program held until its limits are applied
+type limitsGate struct {
+	release   *os.File // Sync pipe, written once the limits are applied
+	status    *os.File // Status pipe, empty once the program was executed
+	childEnds []*os.File
+}
+
+// rlimitResource returns the resource number of a limit name. RLIMIT_NPROC is
+// missing from syscall: it is 6, except on MIPS where 6 is RLIMIT_AS.
+func rlimitResource(name string) int {
+	switch name {
+	case LimitAddressSpace:
+		return syscall.RLIMIT_AS
+	case LimitOpenFiles:
+		return syscall.RLIMIT_NOFILE
+	case LimitCPUTime:
+		return syscall.RLIMIT_CPU
+	case LimitProcesses:
+		if syscall.RLIMIT_AS == 6 {
+			return 8
+		}
+		return 6
+	case LimitCoreSize:
+		return syscall.RLIMIT_CORE
+	}
+	return -1
+}
+
+// setupLimitsGate turns cmd into the start of gorun's own executable, which waits for
+// the limits to be applied to it before executing the original command: none of the
+// program code runs without them. The init process of a sandbox already waits.
+// Credentials set on cmd are moved to the program: the gate runs as gorun itself.
+func setupLimitsGate(cmd *exec.Cmd, l *Limits) (*limitsGate, error) {
+	if len(l.rlimits()) == 0 {
+		return nil, nil
+	}
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	self, err := selfExecutable("Config.Limits")
+	if err != nil {
+		return nil, err
+	}
+
+	spec := limitsExecSpec{Path: cmd.Path, Args: cmd.Args}
+	if attr := cmd.SysProcAttr; attr != nil {
+		if cred := attr.Credential; cred != nil {
+			spec.Credential = &sandboxCredential{Uid: cred.Uid, Gid: cred.Gid, Groups: cred.Groups}
+			attr.Credential = nil
+		}
+		spec.AmbientCaps, attr.AmbientCaps = attr.AmbientCaps, nil
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("limits: %w", err)
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], limitsExecEnv+"="+string(data))
+	cmd.Path = helperPath
+	cmd.Args = []string{"gorun-limits"}
+
+	syncR, syncW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("limits: %w", err)
+	}
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		syncR.Close()
+		syncW.Close()
+		return nil, fmt.Errorf("limits: %w", err)
+	}
+	// Become limitsSyncFD, limitsStatusFD and helperExeFD in the gate
+	cmd.ExtraFiles = []*os.File{syncR, statusW, self}
+
+	return &limitsGate{
+		release:   syncW,
+		status:    statusR,
+		childEnds: []*os.File{syncR, statusW},
+	}, nil
+}
+
+// started releases the pipe ends owned by the gate
+func (g *limitsGate) started() {
+	for _, f := range g.childEnds {
+		f.Close()
+	}
+	g.childEnds = nil
+}
+
+// start lets the gate execute the program, once its limits are applied
+func (g *limitsGate) start() error {
+	defer g.close()
+	if _, err := g.release.Write([]byte{1}); err != nil {
+		return fmt.Errorf("limits: %w", err)
+	}
+
+	g.status.SetReadDeadline(time.Now().Add(limitsExecTimeout))
+	msg, err := io.ReadAll(g.status)
+	if err != nil {
+		return fmt.Errorf("limits: executing the program: %w", err)
+	}
+	if len(msg) > 0 {
+		return errors.New(strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// close releases the pipes of the gate
+func (g *limitsGate) close() {
+	g.started()
+	g.release.Close()
+	g.status.Close()
+}
+
+// limitsExec is the limits gate, it only returns when the program can't be executed
+func limitsExec(data string) int {
+	syscall.CloseOnExec(limitsSyncFD)
+	syscall.CloseOnExec(limitsStatusFD)
+	sync := os.NewFile(limitsSyncFD, "sync")
+	status := os.NewFile(limitsStatusFD, "status")
+
+	var spec limitsExecSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		fmt.Fprintf(status, "limits: %v\n", err)
+		return 1
+	}
+
+	// The parent closes the pipe without writing when the start is abandoned
+	if _, err := sync.Read(make([]byte, 1)); err != nil {
+		return 1
+	}
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, limitsExecEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	// Only this thread switches to the program credentials, exec then drops the others
+	runtime.LockOSThread()
+	if err := switchThreadCredentials(spec.Credential, spec.AmbientCaps); err != nil {
+		fmt.Fprintf(status, "limits: %v\n", err)
+		return 1
+	}
+	err := syscall.Exec(spec.Path, spec.Args, env)
+	fmt.Fprintf(status, "exec %s: %v\n", spec.Path, err)
+	return 1
+}
+
+// applyLimits sets the limits of the started process pid with prlimit(2): the limits
+// gate or the init process of a sandbox, before they start the program itself.
+func applyLimits(pid int, l *Limits) error {
+	for _, limit := range l.rlimits() {
+		value := syscall.Rlimit{Cur: limit.soft, Max: limit.hard}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(pid), uintptr(rlimitResource(limit.name)), uintptr(unsafe.Pointer(&value)), 0, 0, 0)
+		if errno != 0 {
+			if errno == syscall.EPERM {
+				return fmt.Errorf("cannot apply %s=%d: %v (raising a hard limit requires CAP_SYS_RESOURCE)", limit.name, limit.hard, errno)
+			}
+			return fmt.Errorf("cannot apply %s=%d: %v", limit.name, limit.hard, errno)
+		}
+	}
+	return nil
+}
+
+// limitExceeded returns the limit that killed the process, if any
+func limitExceeded(l *Limits, info *ExitInfo, state *os.ProcessState) string {
+	if l == nil || info.Signal == nil {
+		return ""
+	}
+	switch info.Signal {
+	case syscall.SIGXCPU:
+		return LimitCPUTime
+	case syscall.SIGKILL:
+		// The hard CPU limit kills once SIGXCPU was ignored or handled
+		for _, limit := range l.rlimits() {
+			if limit.name == LimitCPUTime && state != nil &&
+				state.UserTime()+state.SystemTime() >= time.Duration(limit.soft)*time.Second {
+				return LimitCPUTime
+			}
+		}
+	}
+	return ""
+}
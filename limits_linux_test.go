@@ -0,0 +1,148 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits_Applied(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"5"} },
+		ExitChan:        make(chan bool),
+		Limits:          &Limits{OpenFiles: 64, AddressSpace: 1 << 30, NoCoreDumps: true},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", gr.GetPID()))
+	if err != nil {
+		t.Fatalf("Failed to read limits: %v", err)
+	}
+	limits := strings.Join(strings.Fields(string(data)), " ")
+	for _, want := range []string{
+		"Max open files 64 64 files",
+		"Max address space 1073741824 1073741824 bytes",
+		"Max core file size 0 0 bytes",
+	} {
+		if !strings.Contains(limits, want) {
+			t.Errorf("Expected %q in /proc/<pid>/limits:\n%s", want, data)
+		}
+	}
+}
+
+func TestLimits_AppliedBeforeProgramRuns(t *testing.T) {
+	// The program reads its limits first thing: they must already be in place
+	out := runToExit(t, &Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", "echo NOFILE=$(ulimit -n)"} },
+		Limits:          &Limits{OpenFiles: 64},
+	})
+	if !strings.Contains(out, "NOFILE=64") {
+		t.Errorf("Expected NOFILE=64 from the program, got %q", out)
+	}
+}
+
+func TestLimits_AsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+
+	// Nothing but the program runs as the user: gorun's own executable may not be reachable by it
+	script := "echo UID=$(id -u) NOFILE=$(ulimit -n); grep CapAmb /proc/self/status"
+	out := runToExit(t, &Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", script} },
+		WorkingDir:      "/",
+		User:            "nobody",
+		AmbientCaps:     []string{"net_bind_service"},
+		Limits:          &Limits{OpenFiles: 64},
+	})
+	if !strings.Contains(out, "UID=65534 NOFILE=64") {
+		t.Errorf("Expected the limits applied to the program of nobody, got %q", out)
+	}
+	if !strings.Contains(out, "CapAmb:\t0000000000000400") {
+		t.Errorf("Expected CAP_NET_BIND_SERVICE as ambient capability, got %q", out)
+	}
+}
+
+func TestLimits_ExecFailure(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: t.TempDir() + "/missing",
+		ExitChan:        make(chan bool),
+		Limits:          &Limits{OpenFiles: 64},
+	})
+	defer gr.StopProgram()
+
+	err := gr.RunProgram()
+	if err == nil || !strings.Contains(err.Error(), "no such file or directory") {
+		t.Fatalf("Expected the exec error of the program, got %v", err)
+	}
+	if gr.IsRunning() {
+		t.Error("Program should not be running")
+	}
+}
+
+func TestLimits_CPUTimeExceeded(t *testing.T) {
+	exits := make(chan *ExitInfo, 1)
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", "while :; do :; done"} },
+		ExitChan:        make(chan bool),
+		Limits:          &Limits{CPUTime: time.Second},
+		OnEvent: func(ev Event) {
+			if ev.Type == EventExited {
+				exits <- ev.Exit
+			}
+		},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	select {
+	case exit := <-exits:
+		if exit.Limit != LimitCPUTime {
+			t.Errorf("Expected the exit to be caused by %s, got %+v", LimitCPUTime, exit)
+		}
+		if !strings.Contains(exit.String(), "RLIMIT_CPU exceeded") {
+			t.Errorf("Unexpected exit description: %s", exit)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("CPU limit never stopped the program")
+	}
+}
+
+func TestLimits_CannotApply(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"5"} },
+		ExitChan:        make(chan bool),
+		// Above fs.nr_open, refused even with CAP_SYS_RESOURCE
+		Limits: &Limits{OpenFiles: 1 << 40},
+	})
+	defer gr.StopProgram()
+
+	err := gr.RunProgram()
+	if err == nil || !strings.Contains(err.Error(), "RLIMIT_NOFILE") {
+		t.Fatalf("Expected an RLIMIT_NOFILE error, got %v", err)
+	}
+	if gr.IsRunning() {
+		t.Error("Program should not run without its limits")
+	}
+}
+
+func TestLimits_CPUTimeRounding(t *testing.T) {
+	limits := (&Limits{CPUTime: 1500 * time.Millisecond}).rlimits()
+	if len(limits) != 1 || limits[0] != (rlimit{LimitCPUTime, 2, 3}) {
+		t.Errorf("Unexpected rlimits: %+v", limits)
+	}
+}
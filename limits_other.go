@@ -0,0 +1,30 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"os"
+	"os/exec"
+)
+
+const limitsSupported = false
+
+type limitsGate struct{}
+
+// setupLimitsGate is never reached with limits, Limits.validate rejects them
+func setupLimitsGate(cmd *exec.Cmd, l *Limits) (*limitsGate, error) {
+	return nil, nil
+}
+
+func (g *limitsGate) started()     {}
+func (g *limitsGate) start() error { return nil }
+func (g *limitsGate) close()       {}
+
+// applyLimits is never reached with limits, Limits.validate rejects them
+func applyLimits(pid int, l *Limits) error {
+	return nil
+}
+
+func limitExceeded(l *Limits, info *ExitInfo, state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,396 @@
+package gorun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProcessDefinition is one program described in a Procfile or JSON process file
+type ProcessDefinition struct {
+	Name   string
+	Line   int // Line of the definition in its file
+	Config *Config
+}
+
+// processFile is the JSON process file format:
+//
+//	{"processes": [{"name": "api", "command": "./api", "args": ["-dev"], ...}]}
+type processFile struct {
+	Processes json.RawMessage `json:"processes"`
+}
+
+type processSpec struct {
+	Name          string            `json:"name"`
+	Command       string            `json:"command"`
+	Args          []string          `json:"args"`
+	WorkingDir    string            `json:"working_dir"`
+	Env           map[string]string `json:"env"`
+	StopSignal    string            `json:"stop_signal"`
+	StopTimeout   string            `json:"stop_timeout"`
+	Restart       string            `json:"restart"`
+	RestartDelay  string            `json:"restart_delay"`
+	MaxAttempts   int               `json:"max_restart_attempts"`
+	Readiness     *readinessSpec    `json:"readiness"`
+	DependsOn     map[string]string `json:"depends_on"` // name: started, ready or completed-successfully
+	KillAllOnStop bool              `json:"kill_all_on_stop"`
+	Ports         []int             `json:"ports"`
+	PortNames     []string          `json:"port_names"`
+}
+
+type readinessSpec struct {
+	TCP     string `json:"tcp"`  // eg: "127.0.0.1:8080"
+	HTTP    string `json:"http"` // eg: "http://127.0.0.1:8080/health"
+	Timeout string `json:"timeout"`
+}
+
+var processNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// LoadProcessFile reads a Procfile, or a JSON process file when path ends in ".json".
+// Relative working dirs are resolved against the directory of path.
+func LoadProcessFile(path string) ([]ProcessDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []ProcessDefinition
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		defs, err = ParseProcessFile(bytes.NewReader(data))
+	} else {
+		defs, err = ParseProcfile(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, def := range defs {
+		if def.Config.WorkingDir == "" {
+			def.Config.WorkingDir = dir
+		} else if !filepath.IsAbs(def.Config.WorkingDir) {
+			def.Config.WorkingDir = filepath.Join(dir, def.Config.WorkingDir)
+		}
+	}
+	return defs, nil
+}
+
+// LoadManager loads a process file and returns a Manager with every program added
+func LoadManager(path string, c *ManagerConfig) (*Manager, error) {
+	defs, err := LoadProcessFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewManager(c)
+	for _, def := range defs {
+		if _, err := m.Add(def.Name, def.Config); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, def.Line, err)
+		}
+	}
+
+	// Report unknown dependencies and cycles now rather than on StartAll
+	if _, err := m.startOrder(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseProcfile parses "name: command args" lines. Blank lines and lines starting
+// with # are ignored, arguments may be quoted with ' or ".
+// Errors are formatted as "line: message".
+func ParseProcfile(r io.Reader) ([]ProcessDefinition, error) {
+	var defs []ProcessDefinition
+	seen := map[string]int{}
+
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		name, command, found := strings.Cut(text, ":")
+		if !found {
+			return nil, fmt.Errorf("%d: expected \"name: command\"", line)
+		}
+		name = strings.TrimSpace(name)
+		if !processNameRe.MatchString(name) {
+			return nil, fmt.Errorf("%d: invalid process name %q", line, name)
+		}
+		if prev, dup := seen[name]; dup {
+			return nil, fmt.Errorf("%d: duplicate process name %q (first defined on line %d)", line, name, prev)
+		}
+		seen[name] = line
+
+		args, err := splitCommand(command)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %v", line, name, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%d: %s: empty command", line, name)
+		}
+
+		defs = append(defs, ProcessDefinition{
+			Name:   name,
+			Line:   line,
+			Config: newLoadedConfig(args[0], args[1:]),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%d: %v", line+1, err)
+	}
+	return defs, nil
+}
+
+// ParseProcessFile parses the JSON process file format, see processSpec for the fields.
+// Errors are formatted as "line: processes[i].field: message".
+func ParseProcessFile(r io.Reader) ([]ProcessDefinition, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var file processFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, jsonError(data, 0, "", err)
+	}
+	if file.Processes == nil {
+		return nil, fmt.Errorf("1: missing \"processes\" list")
+	}
+
+	// Walk the list element by element to know the line of each process
+	base := int64(bytes.Index(data, file.Processes))
+	list := json.NewDecoder(bytes.NewReader(file.Processes))
+	if tok, err := list.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("%d: processes: expected a list", lineAt(data, base))
+	}
+
+	var defs []ProcessDefinition
+	seen := map[string]int{}
+
+	for i := 0; list.More(); i++ {
+		var raw json.RawMessage
+		if err := list.Decode(&raw); err != nil {
+			return nil, jsonError(data, base, fmt.Sprintf("processes[%d]", i), err)
+		}
+		offset := base + list.InputOffset() - int64(len(raw))
+		line := lineAt(data, offset)
+		field := fmt.Sprintf("processes[%d]", i)
+
+		var spec processSpec
+		specDec := json.NewDecoder(bytes.NewReader(raw))
+		specDec.DisallowUnknownFields()
+		if err := specDec.Decode(&spec); err != nil {
+			return nil, jsonError(data, offset, field, err)
+		}
+
+		if spec.Name != "" {
+			field = fmt.Sprintf("processes[%d] (%s)", i, spec.Name)
+		}
+		if prev, dup := seen[spec.Name]; dup {
+			return nil, fmt.Errorf("%d: %s.name: duplicate process name (first defined on line %d)", line, field, prev)
+		}
+		seen[spec.Name] = line
+
+		config, fieldErr := spec.config()
+		if fieldErr != nil {
+			return nil, fmt.Errorf("%d: %s.%v", line, field, fieldErr)
+		}
+
+		defs = append(defs, ProcessDefinition{Name: spec.Name, Line: line, Config: config})
+	}
+
+	return defs, nil
+}
+
+// config validates the spec and builds its Config, errors start with the field name
+func (s *processSpec) config() (*Config, error) {
+	if !processNameRe.MatchString(s.Name) {
+		return nil, fmt.Errorf("name: invalid process name %q", s.Name)
+	}
+	if s.Command == "" {
+		return nil, errors.New("command: required")
+	}
+
+	c := newLoadedConfig(s.Command, s.Args)
+	c.WorkingDir = s.WorkingDir
+	c.KillAllOnStop = s.KillAllOnStop
+	c.Ports = s.Ports
+	c.PortNames = s.PortNames
+
+	keys := make([]string, 0, len(s.Env))
+	for k := range s.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" || strings.Contains(k, "=") {
+			return nil, fmt.Errorf("env: invalid variable name %q", k)
+		}
+		c.Env = append(c.Env, k+"="+s.Env[k])
+	}
+
+	var err error
+	if s.StopSignal != "" {
+		if c.StopSignal, err = ParseSignal(s.StopSignal); err != nil {
+			return nil, fmt.Errorf("stop_signal: %v", err)
+		}
+	}
+	if c.StopTimeout, err = parseDuration(s.StopTimeout); err != nil {
+		return nil, fmt.Errorf("stop_timeout: %v", err)
+	}
+	if c.RestartPolicy, err = ParseRestartPolicy(s.Restart); err != nil {
+		return nil, fmt.Errorf("restart: %v", err)
+	}
+	if c.RestartDelay, err = parseDuration(s.RestartDelay); err != nil {
+		return nil, fmt.Errorf("restart_delay: %v", err)
+	}
+	c.MaxRestartAttempts = s.MaxAttempts
+
+	if r := s.Readiness; r != nil {
+		switch {
+		case r.TCP != "" && r.HTTP != "":
+			return nil, errors.New("readiness: set only one of tcp or http")
+		case r.TCP != "":
+			c.ReadinessProbe = TCPProbe(r.TCP)
+		case r.HTTP != "":
+			c.ReadinessProbe = HTTPProbe(r.HTTP)
+		default:
+			return nil, errors.New("readiness: one of tcp or http is required")
+		}
+		if c.ReadinessTimeout, err = parseDuration(r.Timeout); err != nil {
+			return nil, fmt.Errorf("readiness.timeout: %v", err)
+		}
+	}
+
+	deps := make([]string, 0, len(s.DependsOn))
+	for name := range s.DependsOn {
+		deps = append(deps, name)
+	}
+	sort.Strings(deps)
+	for _, name := range deps {
+		condition, err := parseDependencyCondition(s.DependsOn[name])
+		if err != nil {
+			return nil, fmt.Errorf("depends_on.%s: %v", name, err)
+		}
+		c.DependsOn = append(c.DependsOn, Dependency{Name: name, Condition: condition})
+	}
+
+	return c, nil
+}
+
+func newLoadedConfig(command string, args []string) *Config {
+	return &Config{
+		ExecProgramPath: command,
+		RunArguments:    func() []string { return append([]string(nil), args...) },
+	}
+}
+
+func parseDependencyCondition(s string) (DependencyCondition, error) {
+	for _, c := range []DependencyCondition{DependencyStarted, DependencyReady, DependencyCompletedSuccessfully} {
+		if s == c.String() {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown condition %q (want started, ready or completed-successfully)", s)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return d, nil
+}
+
+// jsonError converts a decoding error into "line: field: message"
+func jsonError(data []byte, base int64, field string, err error) error {
+	offset := base
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset += syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset += typeErr.Offset
+		if typeErr.Field != "" {
+			field = strings.TrimPrefix(field+"."+typeErr.Field, ".")
+		}
+	}
+
+	if field == "" {
+		return fmt.Errorf("%d: %v", lineAt(data, offset), err)
+	}
+	return fmt.Errorf("%d: %s: %v", lineAt(data, offset), field, err)
+}
+
+// lineAt returns the 1 based line of offset in data, skipping leading whitespace
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n", rune(data[offset])) {
+		offset++
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// splitCommand splits a command line into arguments honouring ' and " quotes
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,172 @@
+package gorun
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseProcfile(t *testing.T) {
+	procfile := `# dev processes
+web: ./server -addr ":8080" 'two words'
+
+worker: ./worker
+`
+	defs, err := ParseProcfile(strings.NewReader(procfile))
+	if err != nil {
+		t.Fatalf("ParseProcfile() failed: %v", err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("Expected 2 processes, got %d", len(defs))
+	}
+
+	web := defs[0]
+	if web.Name != "web" || web.Line != 2 || web.Config.ExecProgramPath != "./server" {
+		t.Errorf("Unexpected definition: %+v", web)
+	}
+	if args := web.Config.RunArguments(); !reflect.DeepEqual(args, []string{"-addr", ":8080", "two words"}) {
+		t.Errorf("Unexpected arguments: %q", args)
+	}
+	if defs[1].Name != "worker" || defs[1].Line != 4 {
+		t.Errorf("Unexpected definition: %+v", defs[1])
+	}
+}
+
+func TestParseProcfile_Errors(t *testing.T) {
+	cases := map[string]string{
+		"web ./server":                   "1: expected",
+		"\nbad name: ./server":           `2: invalid process name "bad name"`,
+		"web: ./a\nweb: ./b":             `2: duplicate process name "web" (first defined on line 1)`,
+		"web:   ":                        "1: web: empty command",
+		"# c\nweb: ./server 'unfinished": "2: web: unterminated ' quote",
+	}
+
+	for input, want := range cases {
+		_, err := ParseProcfile(strings.NewReader(input))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("ParseProcfile(%q) error = %v, want prefix %q", input, err, want)
+		}
+	}
+}
+
+func TestParseProcessFile(t *testing.T) {
+	file := `{
+  "processes": [
+    {
+      "name": "db",
+      "command": "./db"
+    },
+    {
+      "name": "api",
+      "command": "./api",
+      "args": ["-dev"],
+      "working_dir": "/srv/api",
+      "env": {"B": "2", "A": "1"},
+      "stop_signal": "SIGINT",
+      "stop_timeout": "5s",
+      "restart": "on-failure",
+      "readiness": {"tcp": "127.0.0.1:8080", "timeout": "20s"},
+      "depends_on": {"db": "ready"}
+    }
+  ]
+}`
+	defs, err := ParseProcessFile(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseProcessFile() failed: %v", err)
+	}
+	if len(defs) != 2 || defs[0].Line != 3 || defs[1].Line != 7 {
+		t.Fatalf("Unexpected definitions: %+v", defs)
+	}
+
+	c := defs[1].Config
+	if c.ExecProgramPath != "./api" || c.WorkingDir != "/srv/api" || !reflect.DeepEqual(c.RunArguments(), []string{"-dev"}) {
+		t.Errorf("Unexpected command: %+v", c)
+	}
+	if !reflect.DeepEqual(c.Env, []string{"A=1", "B=2"}) {
+		t.Errorf("Unexpected env: %q", c.Env)
+	}
+	if c.StopSignal != syscall.SIGINT || c.StopTimeout != 5*time.Second || c.RestartPolicy != RestartOnFailure {
+		t.Errorf("Unexpected stop/restart settings: %+v", c)
+	}
+	if c.ReadinessProbe == nil || c.ReadinessTimeout != 20*time.Second {
+		t.Error("Readiness probe not configured")
+	}
+	if !reflect.DeepEqual(c.DependsOn, []Dependency{{Name: "db", Condition: DependencyReady}}) {
+		t.Errorf("Unexpected dependencies: %+v", c.DependsOn)
+	}
+}
+
+func TestParseProcessFile_Errors(t *testing.T) {
+	cases := map[string]string{
+		"{\n  \"processes\": [\n    {\"name\": \"api\"}\n  ]\n}":                                                                            "3: processes[0] (api).command: required",
+		"{\n  \"processes\": [\n    {\"name\": \"api\", \"command\": \"x\",\n \"restart\": \"maybe\"}\n]}":                                  `3: processes[0] (api).restart: unknown restart policy "maybe"`,
+		"{\"processes\": [\n{\"name\": \"api\", \"command\": \"x\"},\n{\"name\": \"w\", \"command\": \"y\", \"stop_signal\": \"SIGFOO\"}]}": `3: processes[1] (w).stop_signal: unknown signal "SIGFOO"`,
+		"{\"processes\": [\n{\"name\": \"api\", \"command\": \"x\",\n \"args\": \"-dev\"}]}":                                                "3: processes[0].args: json: cannot unmarshal",
+		"{\"processes\": [\n{\"name\": \"api\", \"comand\": \"x\"}]}":                                                                       `2: processes[0]: json: unknown field "comand"`,
+		"{\n\"processes\": [\n{\"name\": \"api\",,}]}":                                                                                      "3: ",
+	}
+
+	for input, want := range cases {
+		_, err := ParseProcessFile(strings.NewReader(input))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("ParseProcessFile(%q)\n error = %v\n want prefix %q", input, err, want)
+		}
+	}
+}
+
+func TestLoadManager(t *testing.T) {
+	dir := t.TempDir()
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	procfile := filepath.Join(dir, "Procfile")
+	os.WriteFile(procfile, []byte("a: "+execPath+"\nb: "+execPath+"\n"), 0644)
+
+	m, err := LoadManager(procfile, nil)
+	if err != nil {
+		t.Fatalf("LoadManager() failed: %v", err)
+	}
+	if !reflect.DeepEqual(m.Names(), []string{"a", "b"}) {
+		t.Errorf("Unexpected names: %v", m.Names())
+	}
+	if m.Get("a").WorkingDir != dir {
+		t.Errorf("Working dir should default to the file directory, got %q", m.Get("a").WorkingDir)
+	}
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	if !m.AllRunning() {
+		t.Error("Loaded programs should be running")
+	}
+	m.StopAll()
+
+	cyclic := filepath.Join(dir, "cyclic.json")
+	os.WriteFile(cyclic, []byte(`{"processes": [
+{"name": "a", "command": "x", "depends_on": {"b": "started"}},
+{"name": "b", "command": "x", "depends_on": {"a": "started"}}]}`), 0644)
+	if _, err := LoadManager(cyclic, nil); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected cycle error, got %v", err)
+	}
+
+	broken := filepath.Join(dir, "Procfile.broken")
+	os.WriteFile(broken, []byte("web ./server\n"), 0644)
+	if _, err := LoadManager(broken, nil); err == nil || !strings.HasPrefix(err.Error(), broken+":1: ") {
+		t.Errorf("Expected error prefixed with file and line, got %v", err)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGINT", "INT", "sigint", " int "} {
+		if sig, err := ParseSignal(name); err != nil || sig != syscall.SIGINT {
+			t.Errorf("ParseSignal(%q) = %v, %v", name, sig, err)
+		}
+	}
+	if _, err := ParseSignal("SIGFOO"); err == nil {
+		t.Error("Expected error for unknown signal")
+	}
+}
@@ -0,0 +1,374 @@
+package gorun
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ManagerConfig configures a Manager running several programs together
+type ManagerConfig struct {
+	Logger  func(message ...any) // Receives the output of every program, one line per call prefixed by its name
+	Ordered bool                 // Start programs one by one in dependency then Add order and stop them in reverse (default: concurrently)
+	NoColor bool                 // Disable ANSI colours in the name prefixes
+}
+
+// Manager owns a set of named GoRun instances and starts/stops them together
+type Manager struct {
+	*ManagerConfig
+	mutex sync.RWMutex
+	names []string // Add order
+	procs map[string]*GoRun
+
+	stateMutex sync.Mutex
+	stateCond  *sync.Cond             // Broadcast on every records and cascading change
+	records    map[string]*procRecord // Current run of every program, see DependsOn
+	cascading  int                    // restartDependants calls running, guarded by stateMutex
+
+	cascadeMutex sync.Mutex // Serializes the restarts of dependants and Stop/StopAll, see restartDependants
+}
+
+// ProcessStatus is a snapshot of one managed program
+type ProcessStatus struct {
+	Name    string
+	Running bool
+	PID     int
+}
+
+var prefixColors = []string{"\x1b[36m", "\x1b[33m", "\x1b[32m", "\x1b[35m", "\x1b[34m", "\x1b[31m"}
+
+const colorReset = "\x1b[0m"
+
+func NewManager(c *ManagerConfig) *Manager {
+	if c == nil {
+		c = &ManagerConfig{}
+	}
+	m := &Manager{
+		ManagerConfig: c,
+		procs:         make(map[string]*GoRun),
+		records:       make(map[string]*procRecord),
+	}
+	m.stateCond = sync.NewCond(&m.stateMutex)
+	return m
+}
+
+// Add registers a program under name. The Config is copied; its Logger, if any,
+// keeps receiving the raw output while the Manager Logger gets it prefixed.
+func (m *Manager) Add(name string, c *Config) (*GoRun, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if name == "" {
+		return nil, errors.New("gorun manager: empty process name")
+	}
+	if _, exists := m.procs[name]; exists {
+		return nil, fmt.Errorf("gorun manager: duplicate process name %q", name)
+	}
+
+	config := *c
+	var flush func()
+	config.Logger, flush = m.prefixLogger(name, len(m.names), c.Logger)
+	config.OnEvent = func(ev Event) {
+		m.recordEvent(name, ev)
+		if ev.Type == EventExited {
+			// Its output is complete, a last line may lack its newline
+			flush()
+		}
+		if c.OnEvent != nil {
+			c.OnEvent(ev)
+		}
+	}
+
+	h := New(&config)
+	m.names = append(m.names, name)
+	m.procs[name] = h
+	return h, nil
+}
+
+// Get returns the program registered under name, or nil
+func (m *Manager) Get(name string) *GoRun {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.procs[name]
+}
+
+// Names returns the registered names in Add order
+func (m *Manager) Names() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]string(nil), m.names...)
+}
+
+// StartAll starts every program, dependencies (Config.DependsOn) before their
+// dependants. If any of them fails to start, the ones already started are stopped
+// again and the errors are returned.
+func (m *Manager) StartAll() error {
+	order, err := m.startOrder()
+	if err != nil {
+		return err
+	}
+	return m.start(order)
+}
+
+// StopAll stops every program, dependants before their dependencies. Independent
+// programs are stopped in parallel so each one gets its own StopTimeout, or one by
+// one in reverse start order when Ordered is set.
+func (m *Manager) StopAll() error {
+	order, err := m.startOrder()
+	if err != nil {
+		// Dependencies are broken, still stop everything
+		order = m.Names()
+	}
+	return m.stopCascading(order)
+}
+
+// Restart restarts the program registered under name and cascades to its dependants:
+// they are stopped first and started again once name reaches their DependsOn condition.
+// A program started again another way, eg: by its RestartPolicy or RunProgram, also
+// restarts its running dependants.
+func (m *Manager) Restart(name string) error {
+	if m.Get(name) == nil {
+		return fmt.Errorf("gorun manager: unknown process %q", name)
+	}
+
+	order, err := m.startOrder()
+	if err != nil {
+		return err
+	}
+
+	dependants := m.dependantsOf(name, order)
+	if err := m.stop(dependants); err != nil {
+		return err
+	}
+	// name itself is replaced by RunProgram following its RestartStrategy
+	return m.start(append([]string{name}, dependants...))
+}
+
+// Stop stops the program registered under name, its dependants first
+func (m *Manager) Stop(name string) error {
+	if m.Get(name) == nil {
+		return fmt.Errorf("gorun manager: unknown process %q", name)
+	}
+
+	order, err := m.startOrder()
+	if err != nil {
+		return m.stopCascading([]string{name})
+	}
+	return m.stopCascading(append([]string{name}, m.dependantsOf(name, order)...))
+}
+
+// stopCascading stops names like stop, never in the middle of a restart of dependants:
+// one that runs after finds them stopped and leaves them so. It returns once no
+// restart of dependants is running.
+func (m *Manager) stopCascading(names []string) error {
+	m.cascadeMutex.Lock()
+	err := m.stop(names)
+	m.cascadeMutex.Unlock()
+
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	for m.cascading > 0 {
+		m.stateCond.Wait()
+	}
+	return err
+}
+
+// start runs names, given in start order, waiting for the dependencies of each one
+func (m *Manager) start(names []string) error {
+	m.resetRecords(names)
+
+	var errs []error
+	started := make([]string, 0, len(names))
+
+	if m.Ordered {
+		for _, name := range names {
+			if err := m.startOne(name); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				break
+			}
+			started = append(started, name)
+		}
+	} else {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, name := range names {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				err := m.startOne(name)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					return
+				}
+				started = append(started, name)
+			}(name)
+		}
+		wg.Wait()
+	}
+
+	if len(errs) > 0 {
+		if stopErr := m.stop(filterOrder(names, started)); stopErr != nil {
+			errs = append(errs, stopErr)
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// startOne waits for the dependencies of name and starts it
+func (m *Manager) startOne(name string) error {
+	h := m.Get(name)
+
+	for _, dep := range h.DependsOn {
+		if err := m.waitFor(dep); err != nil {
+			m.markFailed(name, err)
+			return err
+		}
+	}
+
+	if err := h.RunProgram(); err != nil {
+		m.markFailed(name, err)
+		return err
+	}
+	return nil
+}
+
+// stop stops names, given in start order, dependants before their dependencies
+func (m *Manager) stop(names []string) error {
+	var errs []error
+
+	if m.Ordered {
+		for i := len(names) - 1; i >= 0; i-- {
+			if err := m.Get(names[i]).StopProgram(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	// Each program waits for the dependants being stopped along with it
+	stopped := make(map[string]chan struct{}, len(names))
+	for _, name := range names {
+		stopped[name] = make(chan struct{})
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer close(stopped[name])
+
+			for _, other := range names {
+				for _, dep := range m.Get(other).DependsOn {
+					if dep.Name == name && other != name {
+						<-stopped[other]
+					}
+				}
+			}
+
+			if err := m.Get(name).StopProgram(); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				mu.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// filterOrder returns the values of order present in subset, keeping order
+func filterOrder(order, subset []string) []string {
+	keep := make(map[string]bool, len(subset))
+	for _, name := range subset {
+		keep[name] = true
+	}
+
+	filtered := make([]string, 0, len(subset))
+	for _, name := range order {
+		if keep[name] {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// Status returns a snapshot of every program in Add order
+func (m *Manager) Status() []ProcessStatus {
+	names := m.Names()
+	status := make([]ProcessStatus, 0, len(names))
+	for _, name := range names {
+		h := m.Get(name)
+		status = append(status, ProcessStatus{
+			Name:    name,
+			Running: h.IsRunning(),
+			PID:     h.GetPID(),
+		})
+	}
+	return status
+}
+
+// AllRunning reports whether every registered program is running
+func (m *Manager) AllRunning() bool {
+	for _, s := range m.Status() {
+		if !s.Running {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixLogger returns a logger that splits output into lines prefixed with name,
+// and a flush logging the pending line not terminated by a newline, if any
+func (m *Manager) prefixLogger(name string, index int, forward func(message ...any)) (func(message ...any), func()) {
+	prefix := name + " | "
+	if !m.NoColor {
+		prefix = prefixColors[index%len(prefixColors)] + name + colorReset + " | "
+	}
+
+	var mu sync.Mutex
+	var partial []byte
+
+	log := func(message ...any) {
+		if forward != nil {
+			forward(message...)
+		}
+		if m.Logger == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		partial = append(partial, fmt.Sprint(message...)...)
+		for {
+			i := bytes.IndexByte(partial, '\n')
+			if i < 0 {
+				break
+			}
+			m.Logger(prefix + string(partial[:i]))
+			partial = partial[i+1:]
+		}
+	}
+	flush := func() {
+		if m.Logger == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(partial) > 0 {
+			m.Logger(prefix + string(partial))
+			partial = nil
+		}
+	}
+	return log, flush
+}
@@ -0,0 +1,177 @@
+package gorun
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lineCollector is a thread-safe Logger collecting every call
+type lineCollector struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *lineCollector) log(message ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range message {
+		c.lines = append(c.lines, m.(string))
+	}
+}
+
+func (c *lineCollector) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.lines, "\n")
+}
+
+func TestManager_StartStopAll(t *testing.T) {
+	execPath := buildTestProgram(t, "simple_program")
+	defer os.Remove(execPath)
+
+	out := &lineCollector{}
+	m := NewManager(&ManagerConfig{Logger: out.log, NoColor: true})
+
+	for _, name := range []string{"api", "assets"} {
+		if _, err := m.Add(name, &Config{ExecProgramPath: execPath, ExitChan: make(chan bool)}); err != nil {
+			t.Fatalf("Add(%s) failed: %v", name, err)
+		}
+	}
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	if !m.AllRunning() {
+		t.Errorf("All programs should be running: %+v", m.Status())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	if err := m.StopAll(); err != nil {
+		t.Errorf("StopAll() failed: %v", err)
+	}
+
+	for _, s := range m.Status() {
+		if s.Running || s.PID != 0 {
+			t.Errorf("Program should be stopped: %+v", s)
+		}
+	}
+
+	output := out.String()
+	for _, want := range []string{"api | PROGRAM_STARTED", "assets | PROGRAM_STARTED"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in aggregated output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestManager_LogsLastLineWithoutNewline(t *testing.T) {
+	out := &lineCollector{}
+	m := NewManager(&ManagerConfig{Logger: out.log, NoColor: true})
+	m.Add("job", &Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", "echo first; printf last"} },
+		ExitChan:        make(chan bool),
+	})
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for m.Get("job").IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	output := out.String()
+	if !strings.Contains(output, "job | first\njob | last") {
+		t.Errorf("Expected the last line once the program exited, got:\n%s", output)
+	}
+}
+
+func TestManager_DuplicateName(t *testing.T) {
+	m := NewManager(nil)
+
+	if _, err := m.Add("api", &Config{ExecProgramPath: "test"}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if _, err := m.Add("api", &Config{ExecProgramPath: "test"}); err == nil {
+		t.Error("Add() should reject a duplicate name")
+	}
+}
+
+func TestManager_StartFailureStopsStarted(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	for _, ordered := range []bool{true, false} {
+		m := NewManager(&ManagerConfig{Ordered: ordered})
+		m.Add("ok", &Config{ExecProgramPath: execPath, ExitChan: make(chan bool)})
+		m.Add("broken", &Config{ExecProgramPath: "/non/existent/program", ExitChan: make(chan bool)})
+
+		err := m.StartAll()
+		if err == nil || !strings.Contains(err.Error(), "broken") {
+			t.Errorf("Expected start error naming the broken program, got %v", err)
+		}
+		if m.Get("ok").IsRunning() {
+			t.Errorf("ordered=%v: started programs should be stopped after a failure", ordered)
+		}
+	}
+}
+
+func TestManager_StopAllInParallel(t *testing.T) {
+	execPath := buildTestProgram(t, "stubborn_program")
+	defer os.Remove(execPath)
+
+	m := NewManager(nil)
+	for _, name := range []string{"a", "b", "c"} {
+		m.Add(name, &Config{ExecProgramPath: execPath, ExitChan: make(chan bool), StopTimeout: 500 * time.Millisecond})
+	}
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+
+	// Let the programs install their signal handlers
+	time.Sleep(300 * time.Millisecond)
+
+	start := time.Now()
+	m.StopAll()
+	// Each program needs its whole StopTimeout before being killed
+	elapsed := time.Since(start)
+	if elapsed < 500*time.Millisecond {
+		t.Errorf("StopAll() should honour StopTimeout, took %v", elapsed)
+	}
+	if elapsed > 1200*time.Millisecond {
+		t.Errorf("StopAll() should stop programs in parallel, took %v", elapsed)
+	}
+}
+
+func TestManager_StopCascades(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	m := NewManager(nil)
+	m.Add("db", &Config{ExecProgramPath: execPath})
+	m.Add("api", &Config{ExecProgramPath: execPath, DependsOn: []Dependency{{Name: "db"}}})
+	m.Add("tool", &Config{ExecProgramPath: execPath})
+	defer m.StopAll()
+
+	if err := m.StartAll(); err != nil {
+		t.Fatalf("StartAll() failed: %v", err)
+	}
+	if err := m.Stop("db"); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+
+	for _, s := range m.Status() {
+		if s.Running != (s.Name == "tool") {
+			t.Errorf("%s: unexpected running state %v after stopping db", s.Name, s.Running)
+		}
+	}
+	if err := m.Stop("missing"); err == nil {
+		t.Error("Stop() of an unknown process should fail")
+	}
+}
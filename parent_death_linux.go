@@ -0,0 +1,62 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// applyParentDeath sets the parent death signal and the process group of cmd
+func (h *GoRun) applyParentDeath(cmd *exec.Cmd) error {
+	if h.ParentDeathSignal == nil && !h.ProcessGroup {
+		return nil
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if h.ParentDeathSignal != nil {
+		sig, ok := h.ParentDeathSignal.(syscall.Signal)
+		if !ok {
+			return fmt.Errorf("unsupported parent death signal %v", h.ParentDeathSignal)
+		}
+		cmd.SysProcAttr.Pdeathsig = sig
+	}
+	cmd.SysProcAttr.Setpgid = h.ProcessGroup
+	return nil
+}
+
+// signalProcess sends sig to process, or to its whole process group with ProcessGroup
+func (h *GoRun) signalProcess(process *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !h.ProcessGroup || !ok {
+		return process.Signal(sig)
+	}
+	// The group outlives its leader while members are left: its id can't be reused
+	if err := syscall.Kill(-process.Pid, s); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// waitExited blocks until the process pid exited, without reaping it: until it is
+// reaped its PID, and so the id of the group it leads, can't be reused
+func waitExited(pid int) error {
+	const pPID = 1     // idtype_t P_PID
+	var info [128]byte // siginfo_t
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&info)), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+package gorun
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// parentDeathHostEnv makes the test binary act as a gorun host, see TestParentDeath_Host
+const parentDeathHostEnv = "GORUN_TEST_PARENT_DEATH_HOST"
+
+// TestParentDeath_Host is not a test: it is the host process started by startHost.
+// It runs a program with a background child using the options listed in the
+// environment, prints the PIDs of the program tree and waits to be killed.
+func TestParentDeath_Host(t *testing.T) {
+	options := os.Getenv(parentDeathHostEnv)
+	if options == "" {
+		t.Skip("helper process of the parent death tests")
+	}
+
+	script := "sleep 60 & echo CHILD_STARTED; wait"
+	config := &Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", script} },
+		ExitChan:        make(chan bool),
+	}
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "orphan":
+			// Also a grandchild orphaned right away, like a daemon
+			script = "(sleep 60 &); " + script
+		case "pdeathsig":
+			config.ParentDeathSignal = syscall.SIGKILL
+		case "group":
+			config.ProcessGroup = true
+		case "sandbox":
+			config.Sandbox = &SandboxConfig{}
+		default:
+			if path, ok := strings.CutPrefix(option, "state="); ok {
+				config.StateFile = path
+			}
+		}
+	}
+
+	gr := New(config)
+	if err := gr.RunProgram(); err != nil {
+		fmt.Printf("ERROR=%v\n", err)
+		os.Exit(1)
+	}
+	for !strings.Contains(gr.getOutput(), "CHILD_STARTED") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var pids []string
+	// From the init process: it adopts the orphans
+	for _, pid := range processTree(gr.processPID()) {
+		pids = append(pids, strconv.Itoa(pid))
+	}
+	fmt.Printf("PIDS=%s\n", strings.Join(pids, " "))
+	select {}
+}
+
+// startHost starts a gorun host running a program with options, it returns the
+// host and the PIDs of the program followed by its descendants
+func startHost(t *testing.T, options string) (*exec.Cmd, []int) {
+	t.Helper()
+	host := exec.Command(os.Args[0], "-test.run=^TestParentDeath_Host$")
+	host.Env = append(os.Environ(), parentDeathHostEnv+"="+options)
+	stdout, err := host.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "PIDS=") || strings.HasPrefix(line, "ERROR=") {
+				lines <- line
+			}
+		}
+		close(lines)
+	}()
+
+	select {
+	case line, ok := <-lines:
+		if list, found := strings.CutPrefix(line, "PIDS="); found {
+			var pids []int
+			for _, field := range strings.Fields(list) {
+				pid, _ := strconv.Atoi(field)
+				pids = append(pids, pid)
+			}
+			return host, pids
+		}
+		host.Process.Kill()
+		host.Wait()
+		t.Fatalf("Host failed: %q (ok=%v)", line, ok)
+	case <-time.After(10 * time.Second):
+		host.Process.Kill()
+		host.Wait()
+		t.Fatal("Host did not start the program")
+	}
+	return nil, nil
+}
+
+// killHost kills the host like a crash would and reports which of pids survived
+// after a grace period. Survivors are killed.
+func killHost(t *testing.T, host *exec.Cmd, pids []int) []int {
+	t.Helper()
+	host.Process.Signal(syscall.SIGKILL)
+	host.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	var alive []int
+	for {
+		alive = alive[:0]
+		for _, pid := range pids {
+			if !processGone(pid) {
+				alive = append(alive, pid)
+			}
+		}
+		if len(alive) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, pid := range alive {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return alive
+}
+
+func TestParentDeath_WithoutSignalChildSurvives(t *testing.T) {
+	host, pids := startHost(t, "none")
+
+	alive := killHost(t, host, pids)
+	if len(alive) == 0 || alive[0] != pids[0] {
+		t.Errorf("Without ParentDeathSignal the program should outlive its host, alive: %v of %v", alive, pids)
+	}
+}
+
+func TestParentDeath_SignalKillsProgram(t *testing.T) {
+	host, pids := startHost(t, "pdeathsig,orphan")
+	if len(pids) < 4 {
+		t.Fatalf("Expected the init process, the program, its child and the adopted orphan, got %v", pids)
+	}
+
+	if alive := killHost(t, host, pids); len(alive) > 0 {
+		t.Errorf("Processes %v of the program tree %v survived the death of its host", alive, pids)
+	}
+}
+
+func TestParentDeath_SandboxKillsTree(t *testing.T) {
+	host, pids := startHost(t, "pdeathsig,sandbox")
+	if len(pids) < 3 {
+		t.Fatalf("Expected the init process, the program and its child, got %v", pids)
+	}
+
+	if alive := killHost(t, host, pids); len(alive) > 0 {
+		t.Errorf("Processes %v of the sandbox %v survived the death of its host", alive, pids)
+	}
+}
+
+func TestParentDeath_ForcedStopKillsProgram(t *testing.T) {
+	execPath := buildTestProgram(t, "stubborn_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{
+		ExecProgramPath:   execPath,
+		ExitChan:          make(chan bool),
+		StopTimeout:       200 * time.Millisecond,
+		ParentDeathSignal: syscall.SIGTERM,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	for !strings.Contains(gr.getOutput(), "STUBBORN_PROGRAM_STARTED") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	pid := gr.GetPID()
+	if exe, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); exe != execPath {
+		t.Fatalf("GetPID() should be the program, got %d running %q", pid, exe)
+	}
+
+	// Signals that the init process doesn't forward reach the program too
+	if err := gr.Signal(syscall.SIGSTOP); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stat, err := readProcStat(pid); err != nil || stat.state != "T" {
+		t.Errorf("Expected the program to be stopped, got %+v, %v", stat, err)
+	}
+	gr.Signal(syscall.SIGCONT)
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if exit := gr.LastExit(); exit == nil || exit.Signal != syscall.SIGKILL || exit.PID != pid {
+		t.Errorf("Expected the program %d to be killed, got %v", pid, exit)
+	}
+	if !processGone(pid) {
+		t.Errorf("Program %d ignoring SIGTERM survived the forced stop", pid)
+	}
+}
+
+func TestParentDeath_NoNewPrivsKeepsProgram(t *testing.T) {
+	// The thread starting the program must outlive the start, or the signal is sent right away
+	gr := New(&Config{
+		ExecProgramPath:   "sleep",
+		RunArguments:      func() []string { return []string{"30"} },
+		ExitChan:          make(chan bool),
+		ParentDeathSignal: syscall.SIGKILL,
+		NoNewPrivs:        true,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if !gr.IsRunning() {
+		t.Fatalf("Program was killed after its start: %v", gr.LastExit())
+	}
+}
+
+func TestProcessGroup_StopKillsBackgroundChildren(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", "sleep 60 & echo CHILD=$!; wait"} },
+		ExitChan:        make(chan bool),
+		ProcessGroup:    true,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	var child int
+	deadline := time.Now().Add(3 * time.Second)
+	for child == 0 && time.Now().Before(deadline) {
+		fmt.Sscanf(gr.getOutput(), "CHILD=%d", &child)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if child == 0 {
+		t.Fatalf("Background child not started: %q", gr.getOutput())
+	}
+	if pgid, _ := syscall.Getpgid(child); pgid != gr.GetPID() {
+		t.Errorf("Child should be in the process group %d of the program, got %d", gr.GetPID(), pgid)
+	}
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for !processGone(child) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !processGone(child) {
+		syscall.Kill(child, syscall.SIGKILL)
+		t.Error("Background child survived the stop of its process group")
+	}
+}
+
+func TestProcessGroup_LeaderExitKillsBackgroundChildren(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", "sleep 60 & echo CHILD=$!; sleep 0.2"} },
+		ExitChan:        make(chan bool),
+		ProcessGroup:    true,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	var child int
+	deadline := time.Now().Add(3 * time.Second)
+	for child == 0 && time.Now().Before(deadline) {
+		fmt.Sscanf(gr.getOutput(), "CHILD=%d", &child)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if child == 0 {
+		t.Fatalf("Background child not started: %q", gr.getOutput())
+	}
+
+	// The leader exits on its own, its group is killed before it is reaped
+	deadline = time.Now().Add(3 * time.Second)
+	for gr.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if gr.IsRunning() {
+		t.Fatal("Program should have exited")
+	}
+	if !processGone(child) {
+		syscall.Kill(child, syscall.SIGKILL)
+		t.Error("Background child survived the exit of its process group leader")
+	}
+}
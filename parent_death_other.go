@@ -0,0 +1,26 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// applyParentDeath rejects ParentDeathSignal and ProcessGroup, they are Linux only
+func (h *GoRun) applyParentDeath(cmd *exec.Cmd) error {
+	if h.ParentDeathSignal != nil || h.ProcessGroup {
+		return errors.New("a parent death signal or process group is only supported on Linux")
+	}
+	return nil
+}
+
+func (h *GoRun) signalProcess(process *os.Process, sig os.Signal) error {
+	return process.Signal(sig)
+}
+
+// waitExited is only needed by ProcessGroup
+func waitExited(pid int) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package gorun
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Syscall numbers missing from syscall, see pidfdSyscall
+const (
+	sysPidfdSendSignal = 424
+	sysPidfdOpen       = 434
+)
+
+// pidfdSyscall returns the number of a pidfd syscall: the same on every
+// architecture but MIPS, where the numbering starts at 4000 (o32) or 5000 (n64)
+func pidfdSyscall(n uintptr) uintptr {
+	switch runtime.GOARCH {
+	case "mips", "mipsle":
+		return 4000 + n
+	case "mips64", "mips64le":
+		return 5000 + n
+	}
+	return n
+}
+
+// pidfdOpen returns a pidfd referring to pid as a file usable with pidfdWait.
+// Unlike the PID, it can't refer to another process later. Requires Linux 5.3.
+func pidfdOpen(pid int) (*os.File, error) {
+	fd, _, errno := syscall.Syscall(pidfdSyscall(sysPidfdOpen), uintptr(pid), 0, 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("pidfd_open", errno)
+	}
+	// Non-blocking so that waiting goes through the runtime poller, not a thread
+	if err := syscall.SetNonblock(int(fd), true); err != nil {
+		syscall.Close(int(fd))
+		return nil, os.NewSyscallError("fcntl", err)
+	}
+	return os.NewFile(fd, "pidfd"), nil
+}
+
+// pidfdWait blocks until the process of pidfd has exited, even if it is not a
+// child: the pidfd then becomes readable
+func pidfdWait(pidfd *os.File) error {
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return conn.Read(func(fd uintptr) bool {
+		return pidfdExited(int(fd))
+	})
+}
+
+// pidfdExited polls pidfd without blocking
+func pidfdExited(fd int) bool {
+	pfd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{fd: int32(fd), events: 0x1} // POLLIN
+	var timeout syscall.Timespec
+	n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(unsafe.Pointer(&timeout)), 0, 0, 0)
+	// On error stop waiting rather than spin
+	return errno != 0 || n > 0
+}
+
+// pidfdSignal sends sig to the process of pidfd
+func pidfdSignal(pidfd *os.File, sig syscall.Signal) error {
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall6(pidfdSyscall(sysPidfdSendSignal), fd, uintptr(sig), 0, 0, 0, 0)
+	})
+	if err != nil {
+		return err
+	}
+	if errno == syscall.ESRCH {
+		return os.ErrProcessDone
+	}
+	if errno != 0 {
+		return os.NewSyscallError("pidfd_send_signal", errno)
+	}
+	return nil
+}
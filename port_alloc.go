@@ -0,0 +1,135 @@
+package gorun
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"regexp"
+	"strconv"
+)
+
+// allocatePorts reserves a free localhost TCP port for each name.
+// All listeners are held until every port is chosen so names never share a port.
+func allocatePorts(names []string) (map[string]int, error) {
+	ports := make(map[string]int, len(names))
+	listeners := make([]net.Listener, 0, len(names))
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for _, name := range names {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate port %q: %v", name, err)
+		}
+		listeners = append(listeners, l)
+		ports[name] = l.Addr().(*net.TCPAddr).Port
+	}
+
+	return ports, nil
+}
+
+// nextPortsUnsafe returns the ports for the next instance: the current ones when
+// StickyPorts is set (unless fresh is required) or newly allocated ones.
+// Should only be called when mutex is already held
+func (h *GoRun) nextPortsUnsafe(fresh bool) (map[string]int, error) {
+	if len(h.PortNames) == 0 {
+		return nil, nil
+	}
+
+	h.portsMutex.RLock()
+	current := h.ports
+	h.portsMutex.RUnlock()
+
+	if h.StickyPorts && !fresh && current != nil {
+		return current, nil
+	}
+	return allocatePorts(h.PortNames)
+}
+
+// setPorts publishes ports as the ones of the active instance
+func (h *GoRun) setPorts(ports map[string]int) {
+	h.portsMutex.Lock()
+	defer h.portsMutex.Unlock()
+	h.ports = ports
+}
+
+// AllocatedPorts returns the ports allocated for the active (or last started)
+// instance by name, see Config.PortNames
+func (h *GoRun) AllocatedPorts() map[string]int {
+	h.portsMutex.RLock()
+	defer h.portsMutex.RUnlock()
+	return maps.Clone(h.ports)
+}
+
+// Port returns the port allocated to name for the active (or last started) instance, 0 if none
+func (h *GoRun) Port(name string) int {
+	h.portsMutex.RLock()
+	defer h.portsMutex.RUnlock()
+	return h.ports[name]
+}
+
+// ProcessPort returns the port allocated to name for the instance with the given PID.
+// Useful in a ReadinessProbe, where the instance being probed is not active yet.
+func (h *GoRun) ProcessPort(pid int, name string) int {
+	h.portsMutex.RLock()
+	defer h.portsMutex.RUnlock()
+	return h.portsByPID[pid][name]
+}
+
+func (h *GoRun) trackProcessPorts(pid int, ports map[string]int) {
+	h.portsMutex.Lock()
+	defer h.portsMutex.Unlock()
+
+	if ports == nil {
+		delete(h.portsByPID, pid)
+		return
+	}
+	if h.portsByPID == nil {
+		h.portsByPID = make(map[int]map[string]int)
+	}
+	h.portsByPID[pid] = ports
+}
+
+// portRef matches a {{port "name"}} reference
+var portRef = regexp.MustCompile(`\{\{\s*port\s+"([^"]*)"\s*\}\}`)
+
+// expandPorts replaces {{port "name"}} in s with the allocated ports, any other
+// text, eg: a literal "{{", is left as is. Nothing is expanded without ports.
+func expandPorts(s string, ports map[string]int) (string, error) {
+	if len(ports) == 0 {
+		return s, nil
+	}
+
+	var err error
+	out := portRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := portRef.FindStringSubmatch(ref)[1]
+		port, ok := ports[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown port %q", name)
+			}
+			return ref
+		}
+		return strconv.Itoa(port)
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// expandAllPorts applies expandPorts to every value
+func expandAllPorts(values []string, ports map[string]int) ([]string, error) {
+	out := make([]string, len(values))
+	for i, v := range values {
+		expanded, err := expandPorts(v, ports)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", v, err)
+		}
+		out[i] = expanded
+	}
+	return out, nil
+}
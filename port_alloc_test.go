@@ -0,0 +1,162 @@
+package gorun
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandPorts(t *testing.T) {
+	ports := map[string]int{"http": 8081, "debug": 2345}
+
+	got, err := expandPorts(`-addr=127.0.0.1:{{port "http"}} -dlv={{port "debug"}}`, ports)
+	if err != nil {
+		t.Fatalf("expandPorts() failed: %v", err)
+	}
+	if got != "-addr=127.0.0.1:8081 -dlv=2345" {
+		t.Errorf("Unexpected expansion: %q", got)
+	}
+
+	if got, _ := expandPorts("dev", nil); got != "dev" {
+		t.Errorf("Values without templates should be unchanged, got %q", got)
+	}
+
+	if _, err := expandPorts(`{{port "grpc"}}`, ports); err == nil {
+		t.Error("Expected error for an unknown port name")
+	}
+
+	// Only port references are expanded, and only when ports are allocated
+	literal := `-tmpl={{.Name}} -x={{ -y={{port "http"}}`
+	if got, err := expandPorts(literal, ports); err != nil || got != "-tmpl={{.Name}} -x={{ -y=8081" {
+		t.Errorf("Other braces should be unchanged, got %q, %v", got, err)
+	}
+	if got, err := expandPorts(literal, nil); err != nil || got != literal {
+		t.Errorf("Nothing should be expanded without ports, got %q, %v", got, err)
+	}
+}
+
+func TestAllocatePorts_Distinct(t *testing.T) {
+	ports, err := allocatePorts([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("allocatePorts() failed: %v", err)
+	}
+
+	seen := map[int]bool{}
+	for name, port := range ports {
+		if port == 0 || seen[port] {
+			t.Errorf("Port %q got invalid or duplicated port %d", name, port)
+		}
+		seen[port] = true
+	}
+}
+
+func TestPortNames_InjectedIntoArgsAndEnv(t *testing.T) {
+	execPath := buildTestProgram(t, "args_program")
+	defer os.Remove(execPath)
+
+	config := &Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{`-http={{port "http"}}`} },
+		ExitChan:        make(chan bool),
+		PortNames:       []string{"http"},
+		Env:             []string{`HTTP_PORT={{port "http"}}`},
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	port := gr.Port("http")
+	if port == 0 {
+		t.Fatal("Expected an allocated http port")
+	}
+	output := gr.getOutput()
+	if !strings.Contains(output, fmt.Sprintf("ARGS:-http=%d", port)) {
+		t.Errorf("Expected port in arguments, got: %s", output)
+	}
+	if !strings.Contains(output, fmt.Sprintf("HTTP_PORT:%d", port)) {
+		t.Errorf("Expected port in environment, got: %s", output)
+	}
+}
+
+func TestPortNames_StickyAcrossRestarts(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	for _, sticky := range []bool{true, false} {
+		config := &Config{
+			ExecProgramPath: execPath,
+			ExitChan:        make(chan bool),
+			PortNames:       []string{"http"},
+			StickyPorts:     sticky,
+		}
+
+		gr := New(config)
+		if err := gr.RunProgram(); err != nil {
+			t.Fatalf("RunProgram() failed: %v", err)
+		}
+		first := gr.Port("http")
+		if err := gr.RunProgram(); err != nil {
+			t.Fatalf("RunProgram() restart failed: %v", err)
+		}
+		second := gr.Port("http")
+		gr.StopProgram()
+
+		if sticky && first != second {
+			t.Errorf("Sticky ports should be kept, got %d then %d", first, second)
+		}
+		if !sticky && first == second {
+			t.Errorf("Non sticky ports should be reallocated, got %d twice", first)
+		}
+	}
+}
+
+func TestPortNames_ProxyAndBlueGreen(t *testing.T) {
+	execPath := buildTestProgram(t, "http_program")
+	defer os.Remove(execPath)
+
+	var gr *GoRun
+	config := &Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{`127.0.0.1:{{port "http"}}`} },
+		ExitChan:        make(chan bool),
+		PortNames:       []string{"http"},
+		RestartStrategy: RestartBlueGreen,
+		ReadinessProbe: func(pid int) error {
+			return TCPProbe(fmt.Sprintf("127.0.0.1:%d", gr.ProcessPort(pid, "http")))(pid)
+		},
+		Proxy: &ProxyConfig{
+			ListenAddr: "127.0.0.1:0",
+			TargetAddr: `127.0.0.1:{{port "http"}}`,
+		},
+	}
+
+	gr = New(config)
+	defer gr.StopProxy()
+	defer gr.StopProgram()
+
+	for i := 0; i < 2; i++ {
+		if err := gr.RunProgram(); err != nil {
+			t.Fatalf("RunProgram() #%d failed: %v", i, err)
+		}
+
+		resp, err := http.Get("http://" + gr.ProxyAddr() + "/")
+		if err != nil {
+			t.Fatalf("Request through proxy failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if want := fmt.Sprintf("HTTP_PROGRAM_%d", gr.GetPID()); string(body) != want {
+			t.Errorf("Expected response from active instance %q, got %q", want, body)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const defaultPortWaitTimeout = 5 * time.Second
+
+// freePortsUnsafe makes sure every port in Config.Ports is free before starting the program.
+// A leftover instance of the program is stopped, a port held by any other process
+// is reported as an error naming that process.
+// Should only be called when mutex is already held
+func (h *GoRun) freePortsUnsafe() error {
+	timeout := h.PortWaitTimeout
+	if timeout <= 0 {
+		timeout = defaultPortWaitTimeout
+	}
+
+	for _, port := range h.Ports {
+		if !portInUse(port) {
+			continue
+		}
+
+		pid, err := portOwner(port)
+		if err != nil {
+			return fmt.Errorf("port %d is in use and its owner could not be identified: %v", port, err)
+		}
+
+		switch {
+		case pid > 0 && h.isOwnInstance(pid):
+			h.safeBuffer.Write([]byte(fmt.Sprintf("Port %d held by previous instance %d (%s), stopping it\n", port, pid, processName(pid))))
+			if err := h.stopPortOwner(pid, port); err != nil {
+				return fmt.Errorf("port %d: failed to stop previous instance %d: %v", port, pid, err)
+			}
+		case pid > 0:
+			return fmt.Errorf("port %d: address in use by PID %d (%s)", port, pid, processDescription(pid))
+		}
+
+		// Unknown owner (eg: another user's process) or a stopped instance still releasing it
+		if !waitPortFree(port, timeout) {
+			return fmt.Errorf("port %d is still in use after waiting %v", port, timeout)
+		}
+	}
+
+	return nil
+}
+
+// isOwnInstance reports whether pid is a previous instance of the program: it runs
+// the executable of ExecProgramPath, resolved to the same file, or the StateFile
+// records it. Another executable of the same name is not ours.
+func (h *GoRun) isOwnInstance(pid int) bool {
+	exe := processExe(pid)
+	if exe == "" || h.ExecProgramPath == "" {
+		return false
+	}
+
+	if path, err := exec.LookPath(h.ExecProgramPath); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			if resolved, err := filepath.EvalSymlinks(abs); err == nil && resolved == exe {
+				return true
+			}
+		}
+	}
+
+	if h.StateFile == "" {
+		return false
+	}
+	data, err := readStateFile(h.StateFile)
+	if err != nil {
+		return false
+	}
+	current, err := readProcessIdentity(pid)
+	return err == nil && containsStateEntry(data.Processes, current)
+}
+
+// processDescription names pid for errors: its executable path, else its command name
+func processDescription(pid int) string {
+	if exe := processExe(pid); exe != "" {
+		return exe
+	}
+	return processName(pid)
+}
+
+// stopPortOwner sends SIGTERM to pid and kills it if the port is not released in time
+func (h *GoRun) stopPortOwner(pid, port int) error {
+	process, err := openProcessHandle(pid)
+	if err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return nil
+		}
+		return err
+	}
+	defer process.close()
+
+	// Checked again through the handle: the PID may have been reused meanwhile
+	if !h.isOwnInstance(pid) {
+		return nil
+	}
+
+	if err := process.signal(syscall.SIGTERM); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return nil
+		}
+		return process.signal(syscall.SIGKILL)
+	}
+
+	if waitPortFree(port, gracefulStopTimeout) {
+		return nil
+	}
+	if err := process.signal(syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// portInUse reports whether a TCP listener can't be opened on port
+func portInUse(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// waitPortFree polls until port is free or timeout expires
+func waitPortFree(port int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for portInUse(port) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(readinessPollInterval)
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package gorun
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const tcpListenState = "0A"
+
+// portOwner returns the PID of the process listening on port using /proc/net/tcp{,6}
+// and the socket inodes in /proc/<pid>/fd. It returns 0 if no visible process owns it.
+func portOwner(port int) (int, error) {
+	inodes := map[string]bool{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		if err := listeningInodes(table, port, inodes); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue // Process gone or not ours to inspect
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			if inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+				return pid, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// listeningInodes adds to inodes the socket inodes listening on port found in table
+func listeningInodes(table string, port int, inodes map[string]bool) error {
+	file, err := os.Open(table)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	wantPort := fmt.Sprintf(":%04X", port)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // Skip header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListenState {
+			continue
+		}
+		if strings.HasSuffix(fields[1], wantPort) && fields[9] != "0" {
+			inodes[fields[9]] = true
+		}
+	}
+
+	return scanner.Err()
+}
+
+// processExe returns the executable path of pid, or "" if it can't be read
+func processExe(pid int) string {
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(exe, " (deleted)")
+}
+
+// processCmdline returns the arguments of pid separated by spaces, like pgrep -f
+// matches them, or "" if they can't be read
+func processCmdline(pid int) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+}
+
+// processName returns the command name of pid, or "unknown"
+func processName(pid int) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(comm))
+}
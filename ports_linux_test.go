@@ -0,0 +1,167 @@
+package gorun
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listenPort(t *testing.T) (net.Listener, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	return l, l.Addr().(*net.TCPAddr).Port
+}
+
+func TestPortOwner_IdentifiesListener(t *testing.T) {
+	l, port := listenPort(t)
+	defer l.Close()
+
+	pid, err := portOwner(port)
+	if err != nil {
+		t.Fatalf("portOwner() failed: %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("Expected owner %d, got %d", os.Getpid(), pid)
+	}
+}
+
+func TestPorts_ForeignOwnerFails(t *testing.T) {
+	l, port := listenPort(t)
+	defer l.Close()
+
+	config := &Config{
+		ExecProgramPath: "pwd",
+		ExitChan:        make(chan bool),
+		Ports:           []int{port},
+	}
+
+	gr := New(config)
+
+	err := gr.RunProgram()
+	if err == nil {
+		gr.StopProgram()
+		t.Fatal("RunProgram() should fail when a foreign process holds the port")
+	}
+	if !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("Error should name the offending process: %v", err)
+	}
+	if gr.IsRunning() {
+		t.Error("Program should not have been started")
+	}
+}
+
+func TestPorts_StopsLeftoverInstance(t *testing.T) {
+	execPath := buildTestProgram(t, "http_program")
+	defer os.Remove(execPath)
+
+	l, port := listenPort(t)
+	addr := l.Addr().String()
+	l.Close()
+
+	// Simulate a leftover from a previous run that still holds the port
+	leftover := exec.Command(execPath, addr)
+	if err := leftover.Start(); err != nil {
+		t.Fatalf("Failed to start leftover: %v", err)
+	}
+	defer leftover.Process.Kill()
+
+	if !waitPortInUse(port, 5*time.Second) {
+		t.Fatal("Leftover never listened on its port")
+	}
+
+	_, logger := createTestLogger()
+	config := &Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{addr} },
+		ExitChan:        make(chan bool),
+		Logger:          logger,
+		Ports:           []int{port},
+		ReadinessProbe:  TCPProbe(addr),
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	leftover.Wait()
+	if pid, _ := portOwner(port); pid != gr.GetPID() {
+		t.Errorf("Port should be owned by the new instance %d, got %d", gr.GetPID(), pid)
+	}
+	if !strings.Contains(gr.getOutput(), "previous instance") {
+		t.Errorf("Expected a log about the stopped leftover, got: %s", gr.getOutput())
+	}
+}
+
+func TestPorts_SameNameOtherExecutableFails(t *testing.T) {
+	execPath := buildTestProgram(t, "http_program")
+	defer os.Remove(execPath)
+
+	// Another executable with the same name is not a leftover of ours
+	other := filepath.Join(t.TempDir(), filepath.Base(execPath))
+	content, err := os.ReadFile(execPath)
+	if err != nil {
+		t.Fatalf("Failed to read test program: %v", err)
+	}
+	if err := os.WriteFile(other, content, 0755); err != nil {
+		t.Fatalf("Failed to copy test program: %v", err)
+	}
+
+	l, port := listenPort(t)
+	addr := l.Addr().String()
+	l.Close()
+
+	foreign := exec.Command(other, addr)
+	if err := foreign.Start(); err != nil {
+		t.Fatalf("Failed to start the other program: %v", err)
+	}
+	defer func() {
+		foreign.Process.Kill()
+		foreign.Wait()
+	}()
+	if !waitPortInUse(port, 5*time.Second) {
+		t.Fatal("The other program never listened on its port")
+	}
+
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{addr} },
+		ExitChan:        make(chan bool),
+		Ports:           []int{port},
+	})
+
+	err = gr.RunProgram()
+	if err == nil {
+		gr.StopProgram()
+		t.Fatal("RunProgram() should fail when another executable holds the port")
+	}
+	want := fmt.Sprintf("address in use by PID %d (%s)", foreign.Process.Pid, other)
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected %q in the error, got: %v", want, err)
+	}
+	if foreign.ProcessState != nil || !portInUse(port) {
+		t.Error("The other program should not have been stopped")
+	}
+}
+
+func waitPortInUse(port int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !portInUse(port) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package gorun
+
+// portOwner can't identify port owners without /proc, ports are only waited on
+func portOwner(port int) (int, error) {
+	return 0, nil
+}
+
+func processExe(pid int) string {
+	return ""
+}
+
+func processCmdline(pid int) string {
+	return ""
+}
+
+func processName(pid int) string {
+	return "unknown"
+}
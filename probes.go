@@ -0,0 +1,45 @@
+package gorun
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const probeTimeout = time.Second
+
+// TCPProbe returns a ReadinessProbe that succeeds once addr accepts TCP connections
+func TCPProbe(addr string) func(pid int) error {
+	return func(pid int) error {
+		conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPProbe returns a ReadinessProbe that succeeds once url answers with a 2xx or 3xx status
+func HTTPProbe(url string) func(pid int) error {
+	client := &http.Client{
+		Timeout: probeTimeout,
+		// A redirect already proves the program is serving
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return func(pid int) error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
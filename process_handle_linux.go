@@ -0,0 +1,88 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// processPollInterval is how often the exit of a process is checked without pidfd
+const processPollInterval = 20 * time.Millisecond
+
+// processHandle refers to one process rather than to its PID: once opened,
+// signals and waits through it can't reach a later process reusing the PID.
+// It is a pidfd, or the PID itself before Linux 5.3.
+type processHandle struct {
+	pid   int
+	pidfd *os.File // nil without pidfd support
+}
+
+// openProcessHandle returns a handle of pid, os.ErrProcessDone if it is gone.
+// Check that pid is the expected process after opening the handle, not before.
+func openProcessHandle(pid int) (*processHandle, error) {
+	pidfd, err := pidfdOpen(pid)
+	switch {
+	case err == nil:
+		return &processHandle{pid: pid, pidfd: pidfd}, nil
+	case errors.Is(err, syscall.ESRCH):
+		return nil, os.ErrProcessDone
+	case !errors.Is(err, syscall.ENOSYS):
+		return nil, err
+	}
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return nil, os.ErrProcessDone
+	}
+	return &processHandle{pid: pid}, nil
+}
+
+// signal sends sig, os.ErrProcessDone if the process is gone
+func (p *processHandle) signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return errors.New("unsupported signal type")
+	}
+	if p.pidfd != nil {
+		return pidfdSignal(p.pidfd, s)
+	}
+	if err := syscall.Kill(p.pid, s); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// wait blocks until the process exited or timeout elapsed (never with timeout
+// <= 0) and reports whether it exited. It doesn't reap: a child must still be
+// waited for by its one owner.
+func (p *processHandle) wait(timeout time.Duration) bool {
+	if p.pidfd != nil {
+		if timeout > 0 {
+			p.pidfd.SetReadDeadline(time.Now().Add(timeout))
+			defer p.pidfd.SetReadDeadline(time.Time{})
+		}
+		return pidfdWait(p.pidfd) == nil
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		if stat, err := readProcStat(p.pid); err != nil || stat.state == "Z" || stat.state == "X" {
+			return true
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(processPollInterval)
+	}
+}
+
+func (p *processHandle) close() {
+	if p.pidfd != nil {
+		p.pidfd.Close()
+	}
+}
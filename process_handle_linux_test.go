@@ -0,0 +1,88 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcessHandle_WaitDoesNotReap(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	process, err := openProcessHandle(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("openProcessHandle() failed: %v", err)
+	}
+	defer process.close()
+
+	if process.wait(100 * time.Millisecond) {
+		t.Fatal("wait() reported the exit of a running process")
+	}
+	if err := process.signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("signal() failed: %v", err)
+	}
+	if !process.wait(2 * time.Second) {
+		t.Fatal("wait() did not report the exit")
+	}
+
+	// The owner still gets the exit status
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Sys().(syscall.WaitStatus).Signal() != syscall.SIGTERM {
+		t.Errorf("Expected the owner to reap a SIGTERM exit, got %v", err)
+	}
+
+	// Reaped: the handle can't reach whatever reuses the PID
+	if err := process.signal(syscall.SIGKILL); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Expected os.ErrProcessDone after the exit, got %v", err)
+	}
+	if !process.wait(0) {
+		t.Error("wait() should return at once for an exited process")
+	}
+}
+
+func TestProcessHandle_Gone(t *testing.T) {
+	cmd := exec.Command("sleep", "0")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openProcessHandle(cmd.Process.Pid); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("Expected os.ErrProcessDone for a reaped process, got %v", err)
+	}
+}
+
+func TestKillAllByName_VerifiesCommandLine(t *testing.T) {
+	// Same executable, but only one has the searched marker in its command line
+	target := exec.Command("sleep", "31.4159")
+	other := exec.Command("sleep", "30")
+	for _, cmd := range []*exec.Cmd{target, other} {
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cmd.Process.Kill()
+	}
+	if got := processCmdline(target.Process.Pid); got != "sleep 31.4159" {
+		t.Errorf("processCmdline() = %q", got)
+	}
+
+	if err := KillAllByName("31.4159"); err != nil {
+		t.Fatalf("KillAllByName() failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- target.Wait() }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Matching process was not killed")
+	}
+	if processGone(other.Process.Pid) {
+		t.Error("Process with another command line was killed")
+	}
+}
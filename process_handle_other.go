@@ -0,0 +1,80 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processPollInterval is how often the exit of a process is checked
+const processPollInterval = 50 * time.Millisecond
+
+// processHandle refers to a process by PID, see the Linux version
+type processHandle struct {
+	process *os.Process
+	exited  chan struct{} // Windows: closed once the process exited
+}
+
+// openProcessHandle returns a handle of pid, os.ErrProcessDone if it is gone
+func openProcessHandle(pid int) (*processHandle, error) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, os.ErrProcessDone
+	}
+	p := &processHandle{process: process}
+
+	if runtime.GOOS == "windows" {
+		// The handle opened by FindProcess can wait for any process
+		p.exited = make(chan struct{})
+		go func() {
+			process.Wait()
+			close(p.exited)
+		}()
+		return p, nil
+	}
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		return nil, os.ErrProcessDone
+	}
+	return p, nil
+}
+
+func (p *processHandle) signal(sig os.Signal) error {
+	return p.process.Signal(sig)
+}
+
+// wait blocks until the process exited or timeout elapsed (never with timeout
+// <= 0) and reports whether it exited
+func (p *processHandle) wait(timeout time.Duration) bool {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	if p.exited != nil {
+		select {
+		case <-p.exited:
+			return true
+		case <-deadline:
+			return false
+		}
+	}
+
+	// Only a parent can wait for a process: poll it
+	for {
+		if err := p.process.Signal(syscall.Signal(0)); errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+			return true
+		}
+		select {
+		case <-deadline:
+			return false
+		case <-time.After(processPollInterval):
+		}
+	}
+}
+
+func (p *processHandle) close() {
+	p.process.Release()
+}
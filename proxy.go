@@ -0,0 +1,352 @@
+package gorun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProxyConfig enables a reverse proxy owned by GoRun that listens on a stable address
+// and forwards to the program, holding requests while it restarts or is not yet ready
+type ProxyConfig struct {
+	ListenAddr  string        // Stable address, eg: "127.0.0.1:8080" (port 0 picks a free one)
+	TargetAddr  string        // Program address, eg: "127.0.0.1:8081" or "127.0.0.1:{{port \"http\"}}"
+	HoldTimeout time.Duration // Max time a request waits for the program (default: 30s)
+}
+
+// ProxyMetrics is a snapshot of the reverse proxy counters
+type ProxyMetrics struct {
+	Requests uint64 // Requests received by the proxy
+	Held     uint64 // Requests that had to wait for the program
+	Holding  int64  // Requests waiting right now
+	Timeouts uint64 // Requests answered 503 because the program was not ready in time
+	Errors   uint64 // Requests answered 502 because forwarding failed
+}
+
+const defaultHoldTimeout = 30 * time.Second
+
+// maxReplayBody bounds the request body kept in memory to retry a request
+const maxReplayBody = 1 << 20
+
+var errHoldTimeout = errors.New("gorun proxy: program not ready before hold timeout")
+
+// readyGate tracks whether the active program is ready to receive traffic
+type readyGate struct {
+	mu    sync.Mutex
+	ready bool
+	ch    chan struct{} // Closed while ready
+}
+
+func newReadyGate() *readyGate {
+	return &readyGate{ch: make(chan struct{})}
+}
+
+func (g *readyGate) set(ready bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ready == ready {
+		return
+	}
+	g.ready = ready
+	if ready {
+		close(g.ch)
+	} else {
+		g.ch = make(chan struct{})
+	}
+}
+
+func (g *readyGate) isReady() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.ready
+}
+
+// wait blocks until the gate is ready, the context ends or the deadline passes
+func (g *readyGate) wait(ctx context.Context, deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		g.mu.Lock()
+		ready, ch := g.ready, g.ch
+		g.mu.Unlock()
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return errHoldTimeout
+		}
+	}
+}
+
+// reverseProxy forwards requests to the program and holds them while it is unavailable
+type reverseProxy struct {
+	config    *ProxyConfig
+	gate      *readyGate
+	target    func() string // Resolves config.TargetAddr, see GoRun.proxyTarget
+	handler   *httputil.ReverseProxy
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+
+	requests atomic.Uint64
+	held     atomic.Uint64
+	holding  atomic.Int64
+	timeouts atomic.Uint64
+	errors   atomic.Uint64
+}
+
+func newReverseProxy(c *ProxyConfig, gate *readyGate, target func() string) *reverseProxy {
+	p := &reverseProxy{
+		config:    c,
+		gate:      gate,
+		target:    target,
+		transport: http.DefaultTransport,
+	}
+
+	p.handler = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(&url.URL{Scheme: "http", Host: p.target()})
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+		},
+		Transport: p,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, errHoldTimeout) {
+				p.timeouts.Add(1)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			p.errors.Add(1)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}
+
+	return p
+}
+
+func (p *reverseProxy) holdTimeout() time.Duration {
+	if p.config.HoldTimeout > 0 {
+		return p.config.HoldTimeout
+	}
+	return defaultHoldTimeout
+}
+
+func (p *reverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.requests.Add(1)
+	p.handler.ServeHTTP(w, r)
+}
+
+// RoundTrip waits for the program to be ready before forwarding and retries
+// requests that could not reach it because it was going away or not listening yet
+func (p *reverseProxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline := time.Now().Add(p.holdTimeout())
+	held := false
+
+	attempt, replayable, err := replayableRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := func(wait func() error) error {
+		if !held {
+			held = true
+			p.held.Add(1)
+		}
+		p.holding.Add(1)
+		defer p.holding.Add(-1)
+		return wait()
+	}
+
+	for {
+		if !p.gate.isReady() {
+			if err := hold(func() error { return p.gate.wait(req.Context(), deadline) }); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := p.transport.RoundTrip(attempt)
+		if err == nil || !isDialError(err) || !replayable {
+			return resp, err
+		}
+
+		// The program is restarting or not listening yet: hold and retry
+		err = hold(func() error {
+			if time.Now().After(deadline) {
+				return errHoldTimeout
+			}
+			select {
+			case <-time.After(readinessPollInterval):
+				return nil
+			case <-req.Context().Done():
+				return req.Context().Err()
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// The failed attempt closed the body
+		if attempt, err = rewindRequest(attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// replayableRequest returns req with a body that can be sent again after a failed
+// attempt, the body is read in memory if needed. It reports false if it can't be:
+// larger than maxReplayBody or of unknown length.
+func replayableRequest(req *http.Request) (*http.Request, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return req, true, nil
+	}
+	if req.ContentLength <= 0 || req.ContentLength > maxReplayBody {
+		return req, false, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	out := req.Clone(req.Context())
+	out.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	out.Body, _ = out.GetBody()
+	return out, true, nil
+}
+
+// rewindRequest returns a copy of req to send again, with its body from the start
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.Body = body
+	return out, nil
+}
+
+// start listens on the configured address, it is a no-op if already started
+func (p *reverseProxy) start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.server != nil {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", p.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	p.listener = listener
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(listener)
+
+	return nil
+}
+
+func (p *reverseProxy) stop() error {
+	p.mu.Lock()
+	server := p.server
+	p.server = nil
+	p.listener = nil
+	p.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracefulStopTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return server.Close()
+	}
+	return nil
+}
+
+func (p *reverseProxy) addr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.listener == nil {
+		return ""
+	}
+	return p.listener.Addr().String()
+}
+
+// proxyTarget returns Proxy.TargetAddr with the active instance ports expanded.
+// An invalid template is returned as is so forwarding fails with a visible error.
+func (h *GoRun) proxyTarget() string {
+	addr, err := expandPorts(h.Proxy.TargetAddr, h.AllocatedPorts())
+	if err != nil {
+		return h.Proxy.TargetAddr
+	}
+	return addr
+}
+
+// StartProxy starts the reverse proxy configured in Config.Proxy.
+// RunProgram starts it automatically, the proxy keeps listening across restarts.
+func (h *GoRun) StartProxy() error {
+	if h.proxy == nil {
+		return errors.New("gorun: no proxy configured")
+	}
+	return h.proxy.start()
+}
+
+// StopProxy stops the reverse proxy, StopProgram leaves it running
+func (h *GoRun) StopProxy() error {
+	if h.proxy == nil {
+		return nil
+	}
+	return h.proxy.stop()
+}
+
+// ProxyAddr returns the address the proxy listens on, or "" if it is not running
+func (h *GoRun) ProxyAddr() string {
+	if h.proxy == nil {
+		return ""
+	}
+	return h.proxy.addr()
+}
+
+// ProxyMetrics returns a snapshot of the proxy counters
+func (h *GoRun) ProxyMetrics() ProxyMetrics {
+	if h.proxy == nil {
+		return ProxyMetrics{}
+	}
+	return ProxyMetrics{
+		Requests: h.proxy.requests.Load(),
+		Held:     h.proxy.held.Load(),
+		Holding:  h.proxy.holding.Load(),
+		Timeouts: h.proxy.timeouts.Load(),
+		Errors:   h.proxy.errors.Load(),
+	}
+}
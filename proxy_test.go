@@ -0,0 +1,202 @@
+package gorun
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a localhost address with a currently unused port
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestProxy_HoldsRequestsUntilReady(t *testing.T) {
+	execPath := buildTestProgram(t, "http_program")
+	defer os.Remove(execPath)
+
+	_, logger := createTestLogger()
+	target := freeAddr(t)
+
+	config := &Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{target} },
+		ExitChan:        make(chan bool),
+		Logger:          logger,
+		ReadinessProbe:  TCPProbe(target),
+		Proxy: &ProxyConfig{
+			ListenAddr:  "127.0.0.1:0",
+			TargetAddr:  target,
+			HoldTimeout: 10 * time.Second,
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProxy()
+	defer gr.StopProgram()
+
+	if err := gr.StartProxy(); err != nil {
+		t.Fatalf("StartProxy() failed: %v", err)
+	}
+	proxyURL := "http://" + gr.ProxyAddr() + "/"
+
+	// The request is sent before the program exists and must be held
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(proxyURL)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{body: string(body), err: err}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if m := gr.ProxyMetrics(); m.Holding != 1 {
+		t.Errorf("Expected 1 request being held, got %+v", m)
+	}
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatalf("Held request failed: %v", r.err)
+		}
+		if !strings.HasPrefix(r.body, "HTTP_PROGRAM_") {
+			t.Errorf("Unexpected response body: %q", r.body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Held request was never released")
+	}
+
+	// Restarting keeps the proxy address stable
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() restart failed: %v", err)
+	}
+	resp, err := http.Get(proxyURL)
+	if err != nil {
+		t.Fatalf("Request after restart failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 after restart, got %d", resp.StatusCode)
+	}
+
+	m := gr.ProxyMetrics()
+	if m.Requests != 2 || m.Held < 1 || m.Holding != 0 || m.Timeouts != 0 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+}
+
+func TestProxy_RetriesRequestBody(t *testing.T) {
+	execPath := buildTestProgram(t, "http_program")
+	defer os.Remove(execPath)
+
+	_, logger := createTestLogger()
+	target := freeAddr(t)
+
+	// Without a ReadinessProbe the program gets traffic before it listens: the
+	// first attempts fail to dial and the request is retried
+	config := &Config{
+		ExecProgramPath: execPath,
+		RunArguments:    func() []string { return []string{target} },
+		Env:             []string{"HTTP_PROGRAM_LISTEN_DELAY=300ms"},
+		ExitChan:        make(chan bool),
+		Logger:          logger,
+		Proxy: &ProxyConfig{
+			ListenAddr:  "127.0.0.1:0",
+			TargetAddr:  target,
+			HoldTimeout: 10 * time.Second,
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProxy()
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() restart failed: %v", err)
+	}
+
+	resp, err := http.Post("http://"+gr.ProxyAddr()+"/", "text/plain", strings.NewReader("REQUEST_BODY"))
+	if err != nil {
+		t.Fatalf("POST through the proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading the response failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !strings.HasSuffix(string(body), " REQUEST_BODY") {
+		t.Errorf("Expected the body to reach the program, got %d %q", resp.StatusCode, body)
+	}
+	if m := gr.ProxyMetrics(); m.Held < 1 || m.Errors != 0 {
+		t.Errorf("Expected the request to be held and retried, got %+v", m)
+	}
+}
+
+func TestProxy_HoldTimeout(t *testing.T) {
+	config := &Config{
+		ExecProgramPath: "test",
+		ExitChan:        make(chan bool),
+		Proxy: &ProxyConfig{
+			ListenAddr:  "127.0.0.1:0",
+			TargetAddr:  freeAddr(t),
+			HoldTimeout: 200 * time.Millisecond,
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProxy()
+
+	if err := gr.StartProxy(); err != nil {
+		t.Fatalf("StartProxy() failed: %v", err)
+	}
+
+	resp, err := http.Get("http://" + gr.ProxyAddr() + "/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 on hold timeout, got %d", resp.StatusCode)
+	}
+	if m := gr.ProxyMetrics(); m.Timeouts != 1 || m.Held != 1 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+}
+
+func TestProxy_NotConfigured(t *testing.T) {
+	gr := New(&Config{ExecProgramPath: "test"})
+
+	if err := gr.StartProxy(); err == nil {
+		t.Error("StartProxy() should fail without Config.Proxy")
+	}
+	if gr.ProxyAddr() != "" {
+		t.Error("ProxyAddr() should be empty without Config.Proxy")
+	}
+}
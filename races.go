@@ -0,0 +1,128 @@
+package gorun
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RaceReport is one distinct data race reported by the program since the GoRun
+// was created, across restarts
+type RaceReport struct {
+	Key       string    `json:"key"`   // Identity of the race, see raceKey
+	Crash     *Crash    `json:"crash"` // First report of the race
+	Count     int       `json:"count"` // Times it was reported
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	PID       int       `json:"pid"` // Process of the last report
+}
+
+func (r RaceReport) String() string {
+	return fmt.Sprintf("%s (%d times)", r.Crash, r.Count)
+}
+
+// Accesses returns the stacks of the current and the previous access of a data race
+func (c *Crash) Accesses() (current, previous *Goroutine) {
+	for i, g := range c.Goroutines {
+		if strings.HasPrefix(g.State, "Previous ") {
+			if previous == nil {
+				previous = &c.Goroutines[i]
+			}
+		} else if current == nil {
+			current = &c.Goroutines[i]
+		}
+	}
+	return current, previous
+}
+
+// raceKey identifies a race by the top frames of both accesses, like the race
+// detector does within one process: the same race reached from other callers,
+// or with the accesses in the other order, is the same race
+func raceKey(crash *Crash) string {
+	var frames []string
+	for _, g := range crash.Goroutines {
+		if len(g.Frames) > 0 {
+			f := g.Frames[0]
+			frames = append(frames, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+		}
+	}
+	slices.Sort(frames)
+	return strings.Join(frames, " | ")
+}
+
+// raceLog collects the distinct races of a GoRun, the zero value is ready to use
+type raceLog struct {
+	mutex sync.Mutex
+	races []*RaceReport // In the order they were first seen
+	byKey map[string]*RaceReport
+}
+
+// add records crash, a data race of pid. It returns its report and whether it
+// was seen for the first time.
+func (l *raceLog) add(pid int, crash *Crash) (RaceReport, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	key := raceKey(crash)
+	if r, ok := l.byKey[key]; ok {
+		r.Count++
+		r.LastSeen = now
+		r.PID = pid
+		return *r, false
+	}
+
+	r := &RaceReport{Key: key, Crash: crash, Count: 1, FirstSeen: now, LastSeen: now, PID: pid}
+	if l.byKey == nil {
+		l.byKey = make(map[string]*RaceReport)
+	}
+	l.byKey[key] = r
+	l.races = append(l.races, r)
+	return *r, true
+}
+
+func (l *raceLog) list() []RaceReport {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	races := make([]RaceReport, len(l.races))
+	for i, r := range l.races {
+		races[i] = *r
+	}
+	return races
+}
+
+func (l *raceLog) len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.races)
+}
+
+func (l *raceLog) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.races = nil
+	l.byKey = nil
+}
+
+// recordRace adds a data race of the program to the races of the session, the
+// first report of a race is sent as EventRace
+func (h *GoRun) recordRace(pid int, crash *Crash) {
+	if race, first := h.races.add(pid, crash); first {
+		h.emit(Event{Type: EventRace, PID: pid, Race: &race})
+	}
+}
+
+// Races returns the distinct data races reported by the program since the GoRun
+// was created or ResetRaces, in the order they were first seen. Programs report
+// races when built with -race.
+func (h *GoRun) Races() []RaceReport {
+	return h.races.list()
+}
+
+// ResetRaces forgets the races reported so far
+func (h *GoRun) ResetRaces() {
+	h.races.reset()
+}
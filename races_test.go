@@ -0,0 +1,128 @@
+package gorun
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRace builds a race report with the given top frame lines of both accesses
+func testRace(t *testing.T, readLine, writeLine int, readFirst bool) *Crash {
+	t.Helper()
+	read := fmt.Sprintf("Read at 0x00c000018188 by goroutine 8:\n  main.reader()\n      /src/app/main.go:%d +0x2e\n", readLine)
+	write := fmt.Sprintf("Write at 0x00c000018188 by goroutine 9:\n  main.writer()\n      /src/app/main.go:%d +0x44\n", writeLine)
+	report := read + "\nPrevious " + strings.ToLower(write[:1]) + write[1:]
+	if !readFirst {
+		report = write + "\nPrevious " + strings.ToLower(read[:1]) + read[1:]
+	}
+
+	var races []*Crash
+	d := newCrashDetector(&bytes.Buffer{}, func(c *Crash) { races = append(races, c) })
+	d.Write([]byte("==================\nWARNING: DATA RACE\n" + report + "==================\n"))
+	if len(races) != 1 {
+		t.Fatalf("Expected one race in %q", report)
+	}
+	return races[0]
+}
+
+func TestRaceLog_Dedupe(t *testing.T) {
+	var log raceLog
+
+	first := testRace(t, 10, 20, true)
+	if r, isNew := log.add(100, first); !isNew || r.Count != 1 || r.Crash != first {
+		t.Fatalf("Expected a new race, got %+v", r)
+	}
+	// Another process, with the accesses in the other order
+	if r, isNew := log.add(200, testRace(t, 10, 20, false)); isNew || r.Count != 2 || r.PID != 200 || r.Crash != first {
+		t.Errorf("Expected the same race, got %+v", r)
+	}
+	if _, isNew := log.add(200, testRace(t, 11, 20, true)); !isNew {
+		t.Error("A race at another line should be new")
+	}
+
+	races := log.list()
+	if len(races) != 2 || races[0].Count != 2 || races[1].Count != 1 || races[1].FirstSeen.Before(races[0].FirstSeen) {
+		t.Errorf("Unexpected races: %+v", races)
+	}
+	if s := races[0].String(); !strings.Contains(s, "main.reader at /src/app/main.go:10") || !strings.HasSuffix(s, "(2 times)") {
+		t.Errorf("Unexpected summary %q", s)
+	}
+
+	current, previous := first.Accesses()
+	if current == nil || previous == nil || current.Frames[0].Function != "main.reader" || previous.Frames[0].Function != "main.writer" {
+		t.Errorf("Unexpected accesses %+v and %+v", current, previous)
+	}
+
+	log.reset()
+	if len(log.list()) != 0 {
+		t.Error("reset() should forget the races")
+	}
+}
+
+func TestRaces_AcrossRestarts(t *testing.T) {
+	execPath := filepath.Join(t.TempDir(), "race_program")
+	if out, err := exec.Command("go", "build", "-race", "-o", execPath, filepath.Join("testdata", "race_program.go")).CombinedOutput(); err != nil {
+		t.Skipf("race detector not available: %v\n%s", err, out)
+	}
+
+	var mu sync.Mutex
+	var raceEvents, crashEvents int
+	exited := make(chan struct{}, 2)
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		OnEvent: func(ev Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch ev.Type {
+			case EventRace:
+				raceEvents++
+			case EventCrash:
+				crashEvents++
+			case EventExited:
+				exited <- struct{}{}
+			}
+		},
+	})
+	defer gr.StopProgram()
+
+	for run := 0; run < 2; run++ {
+		if err := gr.RunProgram(); err != nil {
+			t.Fatalf("RunProgram() failed: %v", err)
+		}
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Program did not exit")
+		}
+	}
+
+	races := gr.Races()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(races) == 0 || raceEvents != len(races) || crashEvents != 2*len(races) {
+		t.Fatalf("Expected each race once per run and one event per distinct race, got %d races, %d race and %d crash events", len(races), raceEvents, crashEvents)
+	}
+	for _, r := range races {
+		if r.Count != 2 {
+			t.Errorf("Expected the race in both runs: %v", r)
+		}
+		if current, previous := r.Crash.Accesses(); current == nil || previous == nil {
+			t.Errorf("Expected both accesses: %+v", r.Crash.Goroutines)
+		}
+	}
+	if gr.Status().Races != len(races) {
+		t.Errorf("Status().Races = %d, expected %d", gr.Status().Races, len(races))
+	}
+
+	client := startControl(t, gr)
+	remote, err := client.Races()
+	if err != nil || len(remote) != len(races) || remote[0].Key != races[0].Key || remote[0].Crash.Message != races[0].Crash.Message {
+		t.Errorf("Unexpected races from the control API: %+v, %v", remote, err)
+	}
+}
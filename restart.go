@@ -0,0 +1,180 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// RestartStrategy defines how RunProgram replaces a program that is already running
+type RestartStrategy int
+
+const (
+	// RestartStopFirst stops the running program before starting the new one (default)
+	RestartStopFirst RestartStrategy = iota
+	// RestartBlueGreen starts the new program, waits for its ReadinessProbe and only then
+	// stops the previous one. If the new program never becomes ready it is stopped and
+	// the previous one keeps running. Requires programs that can run side by side,
+	// eg: servers listening on dynamically assigned ports.
+	RestartBlueGreen
+)
+
+const (
+	defaultReadinessTimeout = 10 * time.Second
+	readinessPollInterval   = 50 * time.Millisecond
+	gracefulStopTimeout     = 3 * time.Second
+)
+
+// ActiveProcess returns the process currently considered active, or nil if none is running.
+// With RestartBlueGreen the active process is switched only after the new one is ready.
+func (h *GoRun) ActiveProcess() *os.Process {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.isRunning && h.Cmd != nil {
+		return h.Cmd.Process
+	}
+	return nil
+}
+
+// restartBlueGreenUnsafe replaces the running program following RestartBlueGreen,
+// the previous one stays active while the mutex is released to wait for readiness
+// Should only be called when lifecycle and mutex are already held
+func (h *GoRun) restartBlueGreenUnsafe() error {
+	oldCmd, oldExit := h.Cmd, h.exit
+
+	// Both instances run side by side, the new one can't reuse the allocated ports
+	ports, err := h.nextPortsUnsafe(true)
+	if err != nil {
+		return err
+	}
+
+	cmd, exit, err := h.startCmdUnsafe(ports)
+	if err != nil {
+		// The previous instance is untouched and keeps serving
+		return err
+	}
+
+	if err := h.waitReadyUnsafe(cmd, exit.done); err != nil {
+		// Roll back: drop the new instance, the previous one stays active
+		if stopErr := h.terminateProcess(cmd.Process, exit.done); stopErr != nil {
+			h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: Error stopping not ready program: %v\n", stopErr)))
+		}
+		return fmt.Errorf("blue/green restart rolled back, previous program kept running: %w", err)
+	}
+
+	// Switch the active instance, then retire the previous one
+	h.Cmd = cmd
+	h.exit = exit
+	h.isRunning = true
+	h.attached = false
+	h.startTime = time.Now()
+	h.setPorts(ports)
+	h.ready.set(true)
+	h.emit(Event{Type: EventReady, PID: h.programPID(cmd.Process.Pid)})
+
+	if err := h.terminateProcess(oldCmd.Process, oldExit.done); err != nil {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: Error stopping previous program: %v\n", err)))
+	}
+
+	return nil
+}
+
+// waitReadyUnsafe polls ReadinessProbe until it succeeds, the program exits or the timeout
+// expires. Without a probe the program is considered ready as soon as it has started.
+// The mutex is released while polling, lifecycle keeps other starts and stops out.
+// Should only be called when lifecycle and mutex are already held
+func (h *GoRun) waitReadyUnsafe(cmd *exec.Cmd, exited <-chan struct{}) error {
+	probe := h.ReadinessProbe
+	if h.Debug != nil {
+		// Paused in the debugger or not, the program is up once dlv listens
+		addr, err := h.debugAddrUnsafe()
+		if err != nil {
+			return err
+		}
+		probe = h.debugProbe(addr)
+	}
+	if probe == nil {
+		return nil
+	}
+
+	timeout := h.ReadinessTimeout
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+
+	h.mutex.Unlock()
+	defer h.mutex.Lock()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	pid := cmd.Process.Pid
+	for {
+		err := probe(pid)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-exited:
+			return fmt.Errorf("program %d exited before becoming ready: %w", pid, err)
+		case <-deadline.C:
+			return fmt.Errorf("program %d not ready after %v: %w", pid, timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// terminateProcess stops a process whose reaping is observed through exited,
+// sending the stop signal first and killing it if it does not exit in time. It
+// returns once the process has been reaped, unless it could not be signaled.
+func (h *GoRun) terminateProcess(process *os.Process, exited <-chan struct{}) error {
+	if process == nil {
+		return nil
+	}
+	select {
+	case <-exited:
+		return nil
+	default:
+	}
+
+	// On Windows, we don't have SIGTERM, so we use Kill directly
+	if runtime.GOOS == "windows" {
+		if err := process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		<-exited
+		return nil
+	}
+
+	if err := h.signalProcess(process, h.stopSignal()); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			// Exited on its own, its waiter is about to publish it
+			<-exited
+			return nil
+		}
+		if killErr := process.Kill(); killErr != nil && !errors.Is(killErr, os.ErrProcessDone) {
+			return killErr
+		}
+		<-exited
+		return nil
+	}
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(h.stopTimeout()):
+		fmt.Fprintf(os.Stderr, "Process did not terminate gracefully, forcing kill\n")
+		if err := h.killProcess(process); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		<-exited
+		return nil
+	}
+}
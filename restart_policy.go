@@ -0,0 +1,113 @@
+package gorun
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestartPolicy defines when a program that exits on its own is started again
+type RestartPolicy int
+
+const (
+	RestartNever     RestartPolicy = iota // Never restart automatically (default)
+	RestartOnFailure                      // Restart when the program exits with a non zero code or is killed by a signal
+	RestartAlways                         // Restart whenever the program exits without StopProgram being called
+)
+
+const (
+	defaultRestartDelay = time.Second
+	maxRestartDelay     = 30 * time.Second // Bound of the delay doubled after failed attempts
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+// ParseRestartPolicy parses "never" (or "no"), "on-failure" and "always"
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch s {
+	case "", "never", "no":
+		return RestartNever, nil
+	case "on-failure":
+		return RestartOnFailure, nil
+	case "always":
+		return RestartAlways, nil
+	}
+	return RestartNever, fmt.Errorf("unknown restart policy %q (want never, on-failure or always)", s)
+}
+
+// ShouldRestart reports whether an unexpected exit with exitCode triggers a restart
+func (p RestartPolicy) ShouldRestart(exitCode int) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	}
+	return false
+}
+
+// autoRestart starts the program again after RestartDelay, retrying with a doubled
+// delay while it fails to start, up to MaxRestartAttempts. It gives up as soon as
+// the program was started or stopped by someone else: generation is the one of
+// the instance that exited.
+func (h *GoRun) autoRestart(generation uint64) {
+	delay := h.RestartDelay
+	if delay <= 0 {
+		delay = defaultRestartDelay
+	}
+	for attempt := 1; ; attempt++ {
+		time.Sleep(delay)
+		if !h.tryRestart(generation, attempt) {
+			return
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// tryRestart is one attempt of autoRestart, it reports whether to try again
+func (h *GoRun) tryRestart(generation uint64, attempt int) bool {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.generation != generation {
+		return false
+	}
+
+	h.restarts++
+	h.safeBuffer.Write([]byte(fmt.Sprintf("App: %v restarting (%s, restart #%d)\n", h.ExecProgramPath, h.RestartPolicy, h.restarts)))
+	err := h.runProgramUnsafe()
+	if err == nil {
+		return false
+	}
+	h.safeBuffer.Write([]byte(fmt.Sprintf("App: %v restart failed: %v\n", h.ExecProgramPath, err)))
+	if h.MaxRestartAttempts > 0 && attempt >= h.MaxRestartAttempts {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("App: %v not restarted again after %d failed attempts\n", h.ExecProgramPath, attempt)))
+		// No exit follows: the last one tells how the program last failed, if it ran
+		ev := Event{Type: EventGaveUp, Err: err}
+		if h.lastExit != nil {
+			info := *h.lastExit
+			ev.Exit = &info
+		}
+		h.emit(ev)
+		return false
+	}
+	return true
+}
+
+// Restarts returns how many times the RestartPolicy restarted the program
+func (h *GoRun) Restarts() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.restarts
+}
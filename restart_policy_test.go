@@ -0,0 +1,191 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	for in, want := range map[string]RestartPolicy{"": RestartNever, "no": RestartNever, "on-failure": RestartOnFailure, "always": RestartAlways} {
+		got, err := ParseRestartPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRestartPolicy(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseRestartPolicy("sometimes"); err == nil {
+		t.Error("Expected error for an unknown policy")
+	}
+}
+
+func TestRestartPolicy_OnFailure(t *testing.T) {
+	failPath := buildTestProgram(t, "error_program")
+	defer os.Remove(failPath)
+	okPath := buildTestProgram(t, "args_program")
+	defer os.Remove(okPath)
+
+	failing := New(&Config{ExecProgramPath: failPath, RestartPolicy: RestartOnFailure, RestartDelay: 50 * time.Millisecond})
+	defer failing.StopProgram()
+	if err := failing.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	clean := New(&Config{ExecProgramPath: okPath, RestartPolicy: RestartOnFailure, RestartDelay: 50 * time.Millisecond})
+	if err := clean.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+
+	if failing.Restarts() < 2 {
+		t.Errorf("Failing program should have been restarted repeatedly, got %d restarts", failing.Restarts())
+	}
+	if clean.Restarts() != 0 {
+		t.Errorf("Clean exit should not trigger on-failure restarts, got %d", clean.Restarts())
+	}
+}
+
+func TestRestartPolicy_Always(t *testing.T) {
+	okPath := buildTestProgram(t, "args_program")
+	defer os.Remove(okPath)
+
+	gr := New(&Config{ExecProgramPath: okPath, RestartPolicy: RestartAlways, RestartDelay: 50 * time.Millisecond})
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	gr.StopProgram()
+	restarts := gr.Restarts()
+	if restarts == 0 {
+		t.Fatal("Program should have been restarted after a clean exit")
+	}
+
+	// StopProgram cancels any pending restart
+	time.Sleep(300 * time.Millisecond)
+	if gr.Restarts() != restarts || gr.IsRunning() {
+		t.Errorf("No restart expected after StopProgram, restarts %d -> %d", restarts, gr.Restarts())
+	}
+}
+
+func TestRestartPolicy_NotAfterStop(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{ExecProgramPath: execPath, RestartPolicy: RestartAlways, RestartDelay: 50 * time.Millisecond})
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	gr.StopProgram()
+
+	time.Sleep(300 * time.Millisecond)
+	if gr.Restarts() != 0 || gr.IsRunning() {
+		t.Errorf("A requested stop must not trigger a restart, got %d restarts", gr.Restarts())
+	}
+}
+
+func TestRestartPolicy_RetriesFailedRestart(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	// The instance of the first restart never becomes ready
+	var mu sync.Mutex
+	var pids []int
+	probe := func(pid int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(pids) == 0 || pids[len(pids)-1] != pid {
+			pids = append(pids, pid)
+		}
+		if len(pids) == 2 {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+
+	gr := New(&Config{
+		ExecProgramPath:  execPath,
+		RestartPolicy:    RestartAlways,
+		RestartDelay:     50 * time.Millisecond,
+		ReadinessProbe:   probe,
+		ReadinessTimeout: 200 * time.Millisecond,
+	})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	first := gr.GetPID()
+	if err := gr.ActiveProcess().Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gr.Restarts() < 2 || !gr.IsRunning() || gr.GetPID() == first {
+		if time.Now().After(deadline) {
+			t.Fatalf("Program was not restarted after a failed restart: %d restarts\n%s", gr.Restarts(), gr.getOutput())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !strings.Contains(gr.getOutput(), "restart failed") {
+		t.Errorf("Expected the failed restart in the output, got %s", gr.getOutput())
+	}
+
+	// Nothing left to retry once it runs
+	time.Sleep(300 * time.Millisecond)
+	if restarts := gr.Restarts(); restarts != 2 {
+		t.Errorf("Expected 2 restarts, got %d", restarts)
+	}
+}
+
+func TestRestartPolicy_MaxRestartAttempts(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	var probes atomic.Int32
+	gaveUp := make(chan Event, 1)
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		RestartPolicy:   RestartAlways,
+		RestartDelay:    20 * time.Millisecond,
+		OnEvent: func(ev Event) {
+			if ev.Type == EventGaveUp {
+				gaveUp <- ev
+			}
+		},
+		ReadinessProbe: func(pid int) error {
+			if probes.Add(1) == 1 {
+				return nil
+			}
+			return errors.New("never ready again")
+		},
+		ReadinessTimeout:   100 * time.Millisecond,
+		MaxRestartAttempts: 2,
+	})
+	defer gr.StopProgram()
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	if err := gr.ActiveProcess().Kill(); err != nil {
+		t.Fatalf("Kill() failed: %v", err)
+	}
+
+	select {
+	case ev := <-gaveUp:
+		if ev.Err == nil || !strings.Contains(ev.Err.Error(), "never ready again") || ev.Exit == nil {
+			t.Errorf("Expected the last failure in the event, got %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected to give up after 2 attempts, got:\n%s", gr.getOutput())
+	}
+	if !strings.Contains(gr.getOutput(), "not restarted again after 2 failed attempts") {
+		t.Errorf("Expected giving up to be logged, got:\n%s", gr.getOutput())
+	}
+	time.Sleep(300 * time.Millisecond)
+	if restarts := gr.Restarts(); restarts != 2 || gr.IsRunning() {
+		t.Errorf("Expected 2 restarts and a stopped program, got %d, running %v", restarts, gr.IsRunning())
+	}
+}
@@ -0,0 +1,143 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// processAlive reports whether a process with the given PID still exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+func TestBlueGreen_SwitchesAfterReady(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	_, logger := createTestLogger()
+
+	var probes atomic.Int32
+	config := &Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		Logger:          logger,
+		RestartStrategy: RestartBlueGreen,
+		ReadinessProbe: func(pid int) error {
+			// Become ready on the second poll to exercise the waiting loop
+			if probes.Add(1)%2 == 1 {
+				return errors.New("not ready yet")
+			}
+			return nil
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	oldPID := gr.GetPID()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("blue/green RunProgram() failed: %v", err)
+	}
+	newPID := gr.GetPID()
+
+	if newPID == 0 || newPID == oldPID {
+		t.Fatalf("Expected a new active PID, old=%d new=%d", oldPID, newPID)
+	}
+	if gr.ActiveProcess() == nil || gr.ActiveProcess().Pid != newPID {
+		t.Error("ActiveProcess should point to the new instance")
+	}
+	if processAlive(oldPID) {
+		t.Errorf("Previous instance %d should have been stopped", oldPID)
+	}
+
+	// The retired instance exiting must not mark the new one as stopped
+	time.Sleep(100 * time.Millisecond)
+	if !gr.IsRunning() {
+		t.Error("New instance should still be running")
+	}
+}
+
+func TestBlueGreen_RollbackWhenNotReady(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	_, logger := createTestLogger()
+
+	var readyPID atomic.Int64
+	config := &Config{
+		ExecProgramPath:  execPath,
+		ExitChan:         make(chan bool),
+		Logger:           logger,
+		RestartStrategy:  RestartBlueGreen,
+		ReadinessTimeout: 300 * time.Millisecond,
+		ReadinessProbe: func(pid int) error {
+			// Only the first instance ever becomes ready
+			if readyPID.CompareAndSwap(0, int64(pid)) || readyPID.Load() == int64(pid) {
+				return nil
+			}
+			return errors.New("connection refused")
+		},
+	}
+
+	gr := New(config)
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	oldPID := gr.GetPID()
+
+	err := gr.RunProgram()
+	if err == nil {
+		t.Fatal("Expected blue/green RunProgram() to fail readiness")
+	}
+	if !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if gr.GetPID() != oldPID {
+		t.Errorf("Previous instance should stay active, got PID %d want %d", gr.GetPID(), oldPID)
+	}
+	if !processAlive(oldPID) || !gr.IsRunning() {
+		t.Error("Previous instance should still be running after rollback")
+	}
+}
+
+func TestReadinessProbe_StopFirstFailure(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	_, logger := createTestLogger()
+
+	config := &Config{
+		ExecProgramPath:  execPath,
+		ExitChan:         make(chan bool),
+		Logger:           logger,
+		ReadinessTimeout: 200 * time.Millisecond,
+		ReadinessProbe:   func(pid int) error { return errors.New("never ready") },
+	}
+
+	gr := New(config)
+
+	if err := gr.RunProgram(); err == nil {
+		t.Fatal("Expected RunProgram() to fail when the program never becomes ready")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if gr.IsRunning() {
+		t.Error("Program that never became ready should have been stopped")
+	}
+}
@@ -2,6 +2,7 @@ package gorun
 
 import (
 	"bytes"
+	"strings"
 	"sync"
 )
 
@@ -11,8 +12,12 @@ type SafeBuffer struct {
 	buffer    *bytes.Buffer
 	mutex     sync.RWMutex
 	forwardTo func(message ...any) // Optional function logger to forward data to
+	followers map[chan []byte]struct{}
 }
 
+// followBuffer is the number of writes a follower may lag behind before missing output
+const followBuffer = 256
+
 // NewSafeBuffer creates a new thread-safe buffer
 func NewSafeBuffer() *SafeBuffer {
 	return &SafeBuffer{
@@ -44,6 +49,14 @@ func (sb *SafeBuffer) Write(p []byte) (n int, err error) {
 		sb.forwardTo(string(p))
 	}
 
+	// Never block the program output on a slow follower
+	for ch := range sb.followers {
+		select {
+		case ch <- bytes.Clone(p):
+		default:
+		}
+	}
+
 	return n, err
 }
 
@@ -67,3 +80,52 @@ func (sb *SafeBuffer) Len() int {
 	defer sb.mutex.RUnlock()
 	return sb.buffer.Len()
 }
+
+// Tail returns the last lines of the buffer, all of it if it has fewer lines
+func (sb *SafeBuffer) Tail(lines int) string {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+	return lastLines(sb.buffer.String(), lines)
+}
+
+// Follow returns the last lines of the buffer and a channel receiving every
+// later write, without gap or overlap. Writes are dropped while the channel is
+// full. cancel stops the delivery and closes the channel.
+func (sb *SafeBuffer) Follow(lines int) (tail string, output <-chan []byte, cancel func()) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	ch := make(chan []byte, followBuffer)
+	if sb.followers == nil {
+		sb.followers = make(map[chan []byte]struct{})
+	}
+	sb.followers[ch] = struct{}{}
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			sb.mutex.Lock()
+			defer sb.mutex.Unlock()
+			delete(sb.followers, ch)
+			close(ch)
+		})
+	}
+	return lastLines(sb.buffer.String(), lines), ch, cancel
+}
+
+// lastLines returns the last n lines of s, a trailing newline doesn't start a new line
+func lastLines(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	end := strings.TrimSuffix(s, "\n")
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] == '\n' {
+			n--
+			if n == 0 {
+				return s[i+1:]
+			}
+		}
+	}
+	return s
+}
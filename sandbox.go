@@ -0,0 +1,105 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// SandboxConfig runs the program in new PID, mount, network, IPC and UTS
+// namespaces (Linux). A small init process, gorun's own executable started in
+// a special mode (see Init), prepares the namespaces, starts the program, forwards the stop
+// signals to it and reports how it exited, so stop, output and ExitInfo work as
+// without sandbox. GetPID, ExitInfo.PID, Stats, Signal and the StateFile refer to
+// the program itself, as seen from the host.
+//
+// Without HostNetwork the program only has a loopback interface: ports and
+// readiness probes on it are not reachable from the host.
+type SandboxConfig struct {
+	HostNetwork        bool   // Keep the host network namespace
+	Hostname           string // Hostname in the sandbox (default: "sandbox")
+	UserNamespace      bool   // Run in a user namespace mapping the current user to root. Always used when gorun is not root
+	ReadOnlyWorkingDir bool   // Mount WorkingDir (or the current directory) read-only
+	PrivateTmp         bool   // Mount an empty tmpfs on /tmp. A program under /tmp is still executed, scripts there are not
+}
+
+// sandboxEnv carries the sandboxSpec to the init process
+const sandboxEnv = "GORUN_SANDBOX"
+
+// sandboxSpec is what the init process needs to prepare the sandbox and start the program.
+// Without Namespaces it only supervises the program, see Config.ParentDeathSignal.
+type sandboxSpec struct {
+	Path        string             `json:"path"`
+	Args        []string           `json:"args"`
+	Namespaces  bool               `json:"namespaces"`
+	DeathSignal syscall.Signal     `json:"death_signal,omitempty"` // Sent to every descendant once gorun is gone
+	Hostname    string             `json:"hostname"`
+	LoopbackUp  bool               `json:"loopback_up"`
+	MountProc   bool               `json:"mount_proc"`
+	ReadOnlyDir bool               `json:"read_only_dir"`
+	PrivateTmp  bool               `json:"private_tmp"`
+	Credential  *sandboxCredential `json:"credential,omitempty"`
+	AmbientCaps []uintptr          `json:"ambient_caps,omitempty"`
+}
+
+// sandboxCredential is the Config.User identity, applied to the program by the init process
+type sandboxCredential struct {
+	Uid    uint32   `json:"uid"`
+	Gid    uint32   `json:"gid"`
+	Groups []uint32 `json:"groups"`
+}
+
+// sandboxExit is how the program of a sandbox exited, as reported by its init process
+type sandboxExit struct {
+	code   int
+	signal os.Signal
+}
+
+// err mirrors the error exec.Cmd.Wait returns for the program
+func (e *sandboxExit) err() error {
+	if e.signal != nil {
+		return fmt.Errorf("signal: %v", e.signal)
+	}
+	if e.code != 0 {
+		return fmt.Errorf("exit status %d", e.code)
+	}
+	return nil
+}
+
+// trackSandbox records the sandbox (or subreaper) of a live process, nil forgets it
+func (h *GoRun) trackSandbox(pid int, sb *sandbox) {
+	h.sandboxesMutex.Lock()
+	defer h.sandboxesMutex.Unlock()
+	if sb == nil {
+		delete(h.sandboxes, pid)
+		return
+	}
+	if h.sandboxes == nil {
+		h.sandboxes = make(map[int]*sandbox)
+	}
+	h.sandboxes[pid] = sb
+}
+
+func (h *GoRun) processSandbox(pid int) *sandbox {
+	h.sandboxesMutex.Lock()
+	defer h.sandboxesMutex.Unlock()
+	return h.sandboxes[pid]
+}
+
+// programPID returns the PID of the program started as process pid: the one its
+// init process started when it runs in a sandbox, pid itself otherwise
+func (h *GoRun) programPID(pid int) int {
+	if sb := h.processSandbox(pid); sb != nil && sb.programPID() != 0 {
+		return sb.programPID()
+	}
+	return pid
+}
+
+// signalProgram sends sig like signalProcess, but to the program itself rather
+// than to the init process of its sandbox, which only forwards the stop signals
+func (h *GoRun) signalProgram(process *os.Process, sig os.Signal) error {
+	if sb := h.processSandbox(process.Pid); sb != nil && !h.ProcessGroup {
+		return sb.signal(sig)
+	}
+	return h.signalProcess(process, sig)
+}
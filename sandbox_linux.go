@@ -0,0 +1,502 @@
+package gorun
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// File descriptors of the init process: the parent writes one byte to sandboxSyncFD
+// once the process may start (eg: limits applied) and reads its state from sandboxStatusFD
+const (
+	sandboxSyncFD   = 3
+	sandboxStatusFD = 4
+)
+
+// prSetChildSubreaper makes the orphaned descendants of the init process its children
+const prSetChildSubreaper = 36
+
+// sandboxStartTimeout bounds the time the init process takes to prepare the sandbox
+const sandboxStartTimeout = 10 * time.Second
+
+// sandboxForwardedSignals are passed from the init process to the program
+var sandboxForwardedSignals = []os.Signal{
+	syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT,
+	syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH,
+}
+
+// sandbox is the parent side of a sandboxed program
+type sandbox struct {
+	release    *os.File      // Sync pipe, written once the init process may go on, then kept open while gorun lives
+	statusFile *os.File      // Status pipe
+	status     *bufio.Reader // "started <pid>", "error <msg>", then "exit <code>" or "signal <n>"
+	childEnds  []*os.File    // Pipe ends of the init process, closed once it started
+	namespaces bool          // The program runs in a new PID namespace
+	pid        int           // Host PID of the program, once started
+}
+
+// setupSandbox turns cmd into the start of the init process of a sandbox running
+// the original command. Credentials set on cmd are moved to the program.
+// Without Sandbox but with ParentDeathSignal, the init process runs without namespaces
+// as a subreaper that signals the whole program tree once gorun is gone.
+func (h *GoRun) setupSandbox(cmd *exec.Cmd) (*sandbox, error) {
+	if h.Sandbox == nil && h.ParentDeathSignal == nil {
+		return nil, nil
+	}
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	feature := "Config.Sandbox"
+	if h.Sandbox == nil {
+		feature = "Config.ParentDeathSignal"
+	}
+	self, err := selfExecutable(feature)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := sandboxSpec{
+		Path: cmd.Path,
+		Args: cmd.Args[1:],
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	if cred := attr.Credential; cred != nil {
+		spec.Credential = &sandboxCredential{Uid: cred.Uid, Gid: cred.Gid, Groups: cred.Groups}
+		attr.Credential = nil
+	}
+	spec.AmbientCaps, attr.AmbientCaps = attr.AmbientCaps, nil
+
+	if c := h.Sandbox; c != nil {
+		spec.Namespaces = true
+		spec.Hostname = c.Hostname
+		spec.LoopbackUp = !c.HostNetwork
+		spec.MountProc = true
+		spec.ReadOnlyDir = c.ReadOnlyWorkingDir
+		spec.PrivateTmp = c.PrivateTmp
+		if spec.Hostname == "" {
+			spec.Hostname = "sandbox"
+		}
+
+		attr.Cloneflags |= syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+		if !c.HostNetwork {
+			attr.Cloneflags |= syscall.CLONE_NEWNET
+		}
+		if c.UserNamespace || os.Geteuid() != 0 {
+			attr.Cloneflags |= syscall.CLONE_NEWUSER
+			attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Geteuid(), Size: 1}}
+			attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getegid(), Size: 1}}
+			attr.GidMappingsEnableSetgroups = false
+		}
+	} else {
+		// The init process must outlive gorun to signal the program tree: it watches
+		// the sync pipe instead and the program gets the parent death signal from it
+		spec.DeathSignal, attr.Pdeathsig = attr.Pdeathsig, 0
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], sandboxEnv+"="+string(data))
+	cmd.Path = helperPath
+	cmd.Args = []string{"gorun-sandbox"}
+
+	syncR, syncW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		syncR.Close()
+		syncW.Close()
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	// Become sandboxSyncFD, sandboxStatusFD and helperExeFD in the init process
+	cmd.ExtraFiles = []*os.File{syncR, statusW, self}
+
+	return &sandbox{
+		release:    syncW,
+		statusFile: statusR,
+		status:     bufio.NewReader(statusR),
+		childEnds:  []*os.File{syncR, statusW},
+		namespaces: h.Sandbox != nil,
+	}, nil
+}
+
+// started releases the pipe ends owned by the init process
+func (sb *sandbox) started() {
+	for _, f := range sb.childEnds {
+		f.Close()
+	}
+	sb.childEnds = nil
+}
+
+// start lets the init process initPID go on and waits until it started the program
+func (sb *sandbox) start(initPID int) error {
+	if _, err := sb.release.Write([]byte{1}); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+
+	sb.statusFile.SetReadDeadline(time.Now().Add(sandboxStartTimeout))
+	defer sb.statusFile.SetReadDeadline(time.Time{})
+
+	line, err := sb.status.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("sandbox: init process failed: %w", err)
+	}
+	if msg, ok := strings.CutPrefix(line, "error "); ok {
+		return fmt.Errorf("sandbox: %s", strings.TrimSpace(msg))
+	}
+	var pid int
+	if _, err := fmt.Sscanf(line, "started %d", &pid); err != nil {
+		return fmt.Errorf("sandbox: unexpected status %q", strings.TrimSpace(line))
+	}
+	sb.pid = pid
+	if sb.namespaces {
+		// The init process reports the PID in its own namespace
+		sb.pid = hostPID(initPID, pid)
+	}
+	return nil
+}
+
+// hostPID returns the host PID of the child of initPID that is nsPID in its PID
+// namespace, 0 if it is gone already
+func hostPID(initPID, nsPID int) int {
+	// Every thread lists the children it forked
+	lists, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", initPID))
+	if err != nil {
+		return 0
+	}
+	var children []string
+	for _, list := range lists {
+		if data, err := os.ReadFile(list); err == nil {
+			children = append(children, strings.Fields(string(data))...)
+		}
+	}
+	for _, field := range children {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		status, err := readProcKeyValues(fmt.Sprintf("/proc/%d/status", pid))
+		if err != nil {
+			continue
+		}
+		// NSpid lists the PID in every namespace, the innermost last
+		if ids := strings.Fields(status["NSpid"]); len(ids) > 0 && ids[len(ids)-1] == strconv.Itoa(nsPID) {
+			return pid
+		}
+	}
+	return 0
+}
+
+// programPID returns the host PID of the program, 0 if unknown
+func (sb *sandbox) programPID() int {
+	return sb.pid
+}
+
+// signal sends sig to the program itself
+func (sb *sandbox) signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok || sb.pid == 0 {
+		return fmt.Errorf("sandbox: unsupported signal %v", sig)
+	}
+	if err := syscall.Kill(sb.pid, s); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// kill asks a subreaper to SIGKILL the whole program tree, it exits once the tree
+// is gone. A sandbox is killed with its init process: that ends its PID namespace.
+func (sb *sandbox) kill() error {
+	if sb.namespaces {
+		return errors.New("sandbox: killed with its init process")
+	}
+	if _, err := sb.release.Write([]byte{'k'}); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	return nil
+}
+
+// close releases the pipes of a sandbox that never started
+func (sb *sandbox) close() {
+	sb.started()
+	sb.release.Close()
+	sb.statusFile.Close()
+}
+
+// exit returns how the program exited, nil if the init process didn't report it
+// (eg: it was killed). Call once the init process has been reaped.
+func (sb *sandbox) exit() *sandboxExit {
+	defer sb.statusFile.Close()
+	sb.release.Close()
+
+	line, err := sb.status.ReadString('\n')
+	if err != nil {
+		return nil
+	}
+	var kind string
+	var n int
+	if _, err := fmt.Sscanf(line, "%s %d", &kind, &n); err != nil {
+		return nil
+	}
+	switch kind {
+	case "exit":
+		return &sandboxExit{code: n}
+	case "signal":
+		return &sandboxExit{code: -1, signal: syscall.Signal(n)}
+	}
+	return nil
+}
+
+// sandboxInit is the init process of a sandbox, it returns its exit code
+func sandboxInit(data string) int {
+	syscall.CloseOnExec(sandboxSyncFD)
+	syscall.CloseOnExec(sandboxStatusFD)
+	sync := os.NewFile(sandboxSyncFD, "sync")
+	status := os.NewFile(sandboxStatusFD, "status")
+
+	fail := func(err error) int {
+		fmt.Fprintf(status, "error %v\n", err)
+		return 1
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return fail(err)
+	}
+
+	// The parent closes the pipe without writing when the start is abandoned
+	if _, err := sync.Read(make([]byte, 1)); err != nil {
+		return 1
+	}
+	if spec.DeathSignal == 0 {
+		sync.Close()
+	}
+
+	path := spec.Path
+	if spec.PrivateTmp {
+		// The program would be hidden by the new /tmp: execute it from a descriptor
+		if abs, err := filepath.Abs(path); err == nil && strings.HasPrefix(abs, "/tmp/") {
+			file, err := os.Open(abs)
+			if err != nil {
+				return fail(err)
+			}
+			path = fmt.Sprintf("/proc/self/fd/%d", file.Fd())
+		}
+	}
+
+	if spec.Namespaces {
+		if err := prepareSandbox(&spec); err != nil {
+			return fail(err)
+		}
+	}
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sandboxEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	sys := &syscall.SysProcAttr{AmbientCaps: spec.AmbientCaps, Pdeathsig: spec.DeathSignal}
+	if cred := spec.Credential; cred != nil {
+		sys.Credential = &syscall.Credential{Uid: cred.Uid, Gid: cred.Gid, Groups: cred.Groups}
+	}
+
+	if spec.DeathSignal != 0 {
+		// Adopt the orphans of the program tree, eg: daemonized children, to signal them too
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0, 0, 0, 0); errno != 0 {
+			return fail(fmt.Errorf("child subreaper: %v", errno))
+		}
+	}
+
+	// Notify before starting so no stop signal is lost
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, sandboxForwardedSignals...)
+
+	pid, err := syscall.ForkExec(path, append([]string{spec.Path}, spec.Args...), &syscall.ProcAttr{
+		Env:   env,
+		Files: []uintptr{0, 1, 2},
+		Sys:   sys,
+	})
+	if err != nil {
+		return fail(fmt.Errorf("starting %s: %w", spec.Path, err))
+	}
+	fmt.Fprintf(status, "started %d\n", pid)
+
+	go func() {
+		for sig := range signals {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+
+	// gorun keeps the sync pipe open while it lives, even when killed it gets closed.
+	// It writes to it to force kill the program tree.
+	var draining atomic.Bool
+	if spec.DeathSignal != 0 {
+		go func() {
+			sig := spec.DeathSignal
+			if n, _ := sync.Read(make([]byte, 1)); n == 1 {
+				sig = syscall.SIGKILL
+			}
+			draining.Store(true)
+			signalDescendants(sig)
+		}()
+	}
+
+	// As PID 1 or subreaper, reap every orphan until the program itself exits. Once
+	// gorun is gone or force kills the program, nobody waits for the rest of the tree:
+	// stay until the whole tree is gone.
+	code := 0
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if errors.Is(err, syscall.ECHILD) && draining.Load() {
+			return code
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if wpid != pid {
+			continue
+		}
+		if ws.Signaled() {
+			fmt.Fprintf(status, "signal %d\n", int(ws.Signal()))
+			code = 128 + int(ws.Signal())
+		} else {
+			fmt.Fprintf(status, "exit %d\n", ws.ExitStatus())
+			code = ws.ExitStatus()
+		}
+		if !draining.Load() {
+			return code
+		}
+	}
+}
+
+// signalDescendants sends sig once to every descendant of the init process until
+// none is left, including the orphans it adopts meanwhile
+func signalDescendants(sig syscall.Signal) {
+	signaled := make(map[int]bool)
+	for {
+		tree := processTree(os.Getpid())[1:]
+		if len(tree) == 0 {
+			return
+		}
+		for _, pid := range tree {
+			if !signaled[pid] {
+				signaled[pid] = true
+				syscall.Kill(pid, sig)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// prepareSandbox sets up the namespaces of the init process
+func prepareSandbox(spec *sandboxSpec) error {
+	// Keep the mounts below from propagating back to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+	if spec.MountProc {
+		// A /proc showing the processes of the new PID namespace
+		if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("mounting /proc: %w", err)
+		}
+	}
+	// Before /tmp is replaced: the working directory may be below it
+	if spec.ReadOnlyDir {
+		if err := bindReadOnly(); err != nil {
+			return fmt.Errorf("read-only working directory: %w", err)
+		}
+	}
+	if spec.PrivateTmp {
+		if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mounting /tmp: %w", err)
+		}
+	}
+	if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+		return fmt.Errorf("setting hostname: %w", err)
+	}
+	if spec.LoopbackUp {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("loopback interface: %w", err)
+		}
+	}
+	return nil
+}
+
+// bindReadOnly mounts the current directory read-only over itself
+func bindReadOnly() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount(wd, wd, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+
+	// Flags locked by a user namespace must be kept when remounting
+	const kept = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(wd, &st); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY) | uintptr(st.Flags)&kept
+	if err := syscall.Mount("", wd, "", flags, ""); err != nil {
+		return err
+	}
+	// The current directory still refers to the directory below the mount
+	return os.Chdir(wd)
+}
+
+// loopbackUp brings up lo, which is down in a new network namespace
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// struct ifreq: interface name, then the flags as a short
+	var ifreq [40]byte
+	copy(ifreq[:], "lo")
+	flags := (*uint16)(unsafe.Pointer(&ifreq[syscall.IFNAMSIZ]))
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifreq))); errno != 0 {
+		return errno
+	}
+	*flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifreq))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package gorun
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sandboxReport parses the KEY=value lines of sandbox_program, repeated keys are joined
+func sandboxReport(out string) map[string]string {
+	report := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			if report[key] != "" {
+				value = report[key] + ";" + value
+			}
+			report[key] = value
+		}
+	}
+	return report
+}
+
+func TestSandbox_Namespaces(t *testing.T) {
+	execPath := buildTestProgram(t, "sandbox_program")
+	defer os.Remove(execPath)
+
+	dir := t.TempDir()
+	out := runToExit(t, &Config{
+		ExecProgramPath: execPath,
+		WorkingDir:      dir,
+		Sandbox: &SandboxConfig{
+			Hostname:           "jail",
+			ReadOnlyWorkingDir: true,
+			PrivateTmp:         true,
+		},
+	})
+
+	report := sandboxReport(out)
+	for key, want := range map[string]string{
+		"PPID":             "1", // Started by the init process of the sandbox
+		"HOSTNAME":         "jail",
+		"INTERFACE":        "lo up|loopback|running",
+		"LOOPBACK":         "true",
+		"WORKDIR_WRITABLE": "false",
+		"TMP_ENTRIES":      "0",
+	} {
+		if report[key] != want {
+			t.Errorf("%s: got %q, want %q\n%s", key, report[key], want, out)
+		}
+	}
+
+	// Nothing leaked to the host
+	if host, _ := os.Hostname(); host == "jail" {
+		t.Error("Hostname of the host was changed")
+	}
+	if err := os.WriteFile(dir+"/host_write", []byte("x"), 0644); err != nil {
+		t.Errorf("Working directory should stay writable on the host: %v", err)
+	}
+}
+
+func TestSandbox_UserNamespaceAndHostNetwork(t *testing.T) {
+	execPath := buildTestProgram(t, "sandbox_program")
+	defer os.Remove(execPath)
+
+	out := runToExit(t, &Config{
+		ExecProgramPath: execPath,
+		WorkingDir:      t.TempDir(),
+		Sandbox:         &SandboxConfig{UserNamespace: true, HostNetwork: true},
+	})
+
+	report := sandboxReport(out)
+	if report["PPID"] != "1" || report["WORKDIR_WRITABLE"] != "true" || report["HOSTNAME"] != "sandbox" {
+		t.Errorf("Unexpected sandbox report:\n%s", out)
+	}
+	if !strings.Contains(report["INTERFACE"], ";") {
+		t.Errorf("Expected the host interfaces with HostNetwork, got %q", report["INTERFACE"])
+	}
+}
+
+func TestSandbox_StopAndExitInfo(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	exits := make(chan *ExitInfo, 1)
+	gr := New(&Config{
+		ExecProgramPath: execPath,
+		ExitChan:        make(chan bool),
+		Sandbox:         &SandboxConfig{},
+		OnEvent: func(ev Event) {
+			if ev.Type == EventExited {
+				exits <- ev.Exit
+			}
+		},
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !strings.Contains(gr.getOutput(), "LONG_TICK_") {
+		t.Errorf("Output of the sandboxed program is missing: %q", gr.getOutput())
+	}
+	pid := gr.GetPID()
+	if exe, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); exe != execPath {
+		t.Errorf("GetPID() should be the program, got %d running %q", pid, exe)
+	}
+
+	start := time.Now()
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("The stop signal should reach the program, stopping took %v", elapsed)
+	}
+
+	select {
+	case exit := <-exits:
+		if exit.Signal != syscall.SIGTERM || exit.Code != -1 || !exit.Requested || exit.PID != pid {
+			t.Errorf("Expected the program to be terminated by SIGTERM, got %+v", exit)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No exit event")
+	}
+	if strings.Contains(gr.getOutput(), "closed with error") {
+		t.Errorf("A requested stop should not be logged as an error: %q", gr.getOutput())
+	}
+}
+
+func TestSandbox_StartError(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+	// Only root is mapped in the user namespace: switching to 4321 fails inside
+	gr := New(&Config{
+		ExecProgramPath: "/bin/sh",
+		WorkingDir:      t.TempDir(),
+		ExitChan:        make(chan bool),
+		User:            "4321",
+		Sandbox:         &SandboxConfig{UserNamespace: true},
+	})
+	defer gr.StopProgram()
+
+	err := gr.RunProgram()
+	if err == nil || !strings.Contains(err.Error(), "sandbox") {
+		t.Errorf("Expected a sandbox start error, got %v", err)
+	}
+	if gr.IsRunning() {
+		t.Error("Nothing should run after a sandbox start error")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+
+package gorun
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+type sandbox struct{}
+
+// setupSandbox rejects Config.Sandbox, namespaces are Linux only
+func (h *GoRun) setupSandbox(cmd *exec.Cmd) (*sandbox, error) {
+	if h.Sandbox != nil {
+		return nil, errors.New("sandbox is only supported on Linux")
+	}
+	return nil, nil
+}
+
+func (sb *sandbox) started()               {}
+func (sb *sandbox) start(int) error        { return nil }
+func (sb *sandbox) close()                 {}
+func (sb *sandbox) exit() *sandboxExit     { return nil }
+func (sb *sandbox) programPID() int        { return 0 }
+func (sb *sandbox) signal(os.Signal) error { return errors.ErrUnsupported }
+func (sb *sandbox) kill() error            { return errors.ErrUnsupported }
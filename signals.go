@@ -0,0 +1,51 @@
+package gorun
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrNotRunning is returned by the operations that need a running program
+var ErrNotRunning = errors.New("program is not running")
+
+// signalNames maps the names accepted by ParseSignal, completed per platform
+var signalNames = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses a signal name like "SIGINT", "INT" or "sigint"
+func ParseSignal(name string) (os.Signal, error) {
+	key := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signalNames[key]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
+
+// Signal sends sig to the running program, or to its whole process group with
+// ProcessGroup. An exit it causes is not requested: the RestartPolicy applies.
+func (h *GoRun) Signal(sig os.Signal) error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.Debug != nil {
+		return ErrDebugMode
+	}
+	if !h.isRunning || h.Cmd == nil || h.Cmd.Process == nil || h.exit == nil || h.exit.published() {
+		return ErrNotRunning
+	}
+	if err := h.signalProgram(h.Cmd.Process, sig); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return ErrNotRunning
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package gorun
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not available on Windows")
+	}
+	gr := startGoroutinesProgram(t, &Config{})
+
+	if err := gr.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); !strings.Contains(gr.getOutput(), "GOROUTINES_PROGRAM_RELOADED"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("Program did not handle SIGHUP: %q", gr.getOutput())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !gr.IsRunning() {
+		t.Error("Program should keep running after SIGHUP")
+	}
+}
+
+func TestSignal_NotRunning(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath: "sh",
+		RunArguments:    func() []string { return []string{"-c", "exit 0"} },
+		ExitChan:        make(chan bool),
+	})
+	if err := gr.Signal(os.Interrupt); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning before RunProgram, got %v", err)
+	}
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); gr.IsRunning() && time.Now().Before(deadline); {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := gr.Signal(os.Interrupt); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning after the program exited, got %v", err)
+	}
+}
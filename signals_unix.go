@@ -0,0 +1,11 @@
+//go:build unix
+
+package gorun
+
+import "syscall"
+
+func init() {
+	signalNames["USR1"] = syscall.SIGUSR1
+	signalNames["USR2"] = syscall.SIGUSR2
+	signalNames["WINCH"] = syscall.SIGWINCH
+}
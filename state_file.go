@@ -0,0 +1,235 @@
+package gorun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// errProcessGone is returned by readProcessIdentity for a process that exited
+var errProcessGone = errors.New("process is gone")
+
+// orphanKillTimeout bounds the wait for an orphan to die after SIGKILL
+const orphanKillTimeout = 2 * time.Second
+
+// stateFileEntry identifies one running instance. The start time and boot id tell
+// it apart from a later process reusing its PID.
+type stateFileEntry struct {
+	PID        int       `json:"pid"`
+	StartTime  uint64    `json:"start_time"` // Clock ticks since boot, field 22 of /proc/<pid>/stat
+	BootID     string    `json:"boot_id"`
+	Executable string    `json:"executable"`
+	ArgsHash   string    `json:"args_hash"` // SHA-256 of the NUL separated arguments
+	Started    time.Time `json:"started"`
+}
+
+type stateFileData struct {
+	Processes []stateFileEntry `json:"processes"`
+}
+
+// sameProcess reports whether e and other describe the same process
+func (e stateFileEntry) sameProcess(other stateFileEntry) bool {
+	return e.PID == other.PID && e.StartTime == other.StartTime && e.BootID == other.BootID &&
+		e.Executable == other.Executable && e.ArgsHash == other.ArgsHash
+}
+
+// readStateFile returns the entries of path, none if it doesn't exist
+func readStateFile(path string) (stateFileData, error) {
+	var data stateFileData
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return data, err
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return data, fmt.Errorf("state file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// writeStateFile replaces path atomically, or removes it when there is no entry left
+func writeStateFile(path string, data stateFileData) error {
+	if len(data.Processes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// updateStateFile applies update to the entries of the StateFile
+func (h *GoRun) updateStateFile(update func([]stateFileEntry) []stateFileEntry) error {
+	h.stateFileMutex.Lock()
+	defer h.stateFileMutex.Unlock()
+
+	data, err := readStateFile(h.StateFile)
+	if err != nil {
+		return err
+	}
+	data.Processes = update(data.Processes)
+	return writeStateFile(h.StateFile, data)
+}
+
+// recordProcess adds the started instance pid to the StateFile
+func (h *GoRun) recordProcess(pid int) {
+	if h.StateFile == "" {
+		return
+	}
+	entry, err := readProcessIdentity(pid)
+	if err == nil {
+		entry.Started = time.Now()
+		err = h.updateStateFile(func(entries []stateFileEntry) []stateFileEntry {
+			return append(removeStateEntry(entries, pid), entry)
+		})
+	}
+	if err != nil {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: not recorded in state file: %v\n", err)))
+	}
+}
+
+// forgetProcess removes the reaped instance pid from the StateFile
+func (h *GoRun) forgetProcess(pid int) {
+	if h.StateFile == "" {
+		return
+	}
+	err := h.updateStateFile(func(entries []stateFileEntry) []stateFileEntry {
+		return removeStateEntry(entries, pid)
+	})
+	if err != nil {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: not removed from state file: %v\n", err)))
+	}
+}
+
+func removeStateEntry(entries []stateFileEntry, pid int) []stateFileEntry {
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.PID != pid {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// stopOrphansUnsafe stops the instances recorded in the StateFile by a previous
+// gorun, once per GoRun. A process is only signaled once verified to be the
+// recorded one, never because of its name.
+// Should only be called when mutex is already held
+func (h *GoRun) stopOrphansUnsafe() {
+	if h.StateFile == "" || h.orphansChecked {
+		return
+	}
+	h.orphansChecked = true
+
+	data, err := readStateFile(h.StateFile)
+	if err != nil {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: %v\n", err)))
+		return
+	}
+	for _, entry := range data.Processes {
+		stopped, err := h.stopOrphan(entry)
+		if err != nil {
+			h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: orphan %s (PID %d) not stopped: %v\n", entry.Executable, entry.PID, err)))
+			continue
+		}
+		if stopped {
+			h.safeBuffer.Write([]byte(fmt.Sprintf("Stopped orphan %s (PID %d) left by a previous run\n", entry.Executable, entry.PID)))
+		}
+	}
+
+	// Whatever was not stopped is gone or another process now
+	err = h.updateStateFile(func(entries []stateFileEntry) []stateFileEntry {
+		kept := entries[:0]
+		for _, e := range entries {
+			if !containsStateEntry(data.Processes, e) {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		h.safeBuffer.Write([]byte(fmt.Sprintf("Warning: %v\n", err)))
+	}
+}
+
+func containsStateEntry(entries []stateFileEntry, entry stateFileEntry) bool {
+	for _, e := range entries {
+		if e.sameProcess(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// stopOrphan stops the process of entry with the stop signal, then SIGKILL after
+// the stop timeout. It returns false if the process is gone or its PID was reused.
+func (h *GoRun) stopOrphan(entry stateFileEntry) (bool, error) {
+	process, err := openProcessHandle(entry.PID)
+	if err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer process.close()
+
+	// Verified once the handle is open: signals can't reach a process reusing the PID
+	current, err := readProcessIdentity(entry.PID)
+	if errors.Is(err, errProcessGone) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !current.sameProcess(entry) {
+		return false, nil
+	}
+
+	if err := process.signal(h.stopSignal()); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			return false, nil
+		}
+		return false, err
+	}
+	if process.wait(h.stopTimeout()) {
+		return true, nil
+	}
+
+	if err := process.signal(syscall.SIGKILL); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return false, err
+	}
+	if !process.wait(orphanKillTimeout) {
+		return false, errors.New("still running after SIGKILL")
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package gorun
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readProcessIdentity reads what identifies process pid from /proc
+func readProcessIdentity(pid int) (stateFileEntry, error) {
+	stat, err := readProcStat(pid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stateFileEntry{}, errProcessGone
+		}
+		return stateFileEntry{}, err
+	}
+	// A zombie is only waiting to be reaped by its parent
+	if stat.state == "Z" || stat.state == "X" {
+		return stateFileEntry{}, errProcessGone
+	}
+
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stateFileEntry{}, errProcessGone
+		}
+		return stateFileEntry{}, err
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stateFileEntry{}, errProcessGone
+		}
+		return stateFileEntry{}, err
+	}
+	bootID, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return stateFileEntry{}, err
+	}
+
+	hash := sha256.Sum256(cmdline)
+	return stateFileEntry{
+		PID:       pid,
+		StartTime: stat.startTime,
+		BootID:    strings.TrimSpace(string(bootID)),
+		// Rebuilding a program replaces its file while the old process runs
+		Executable: strings.TrimSuffix(exe, " (deleted)"),
+		ArgsHash:   hex.EncodeToString(hash[:]),
+	}, nil
+}
@@ -0,0 +1,143 @@
+package gorun
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStateFile_RecordsRunningInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sleep.json")
+	gr := New(&Config{
+		ExecProgramPath: "sleep",
+		RunArguments:    func() []string { return []string{"30"} },
+		ExitChan:        make(chan bool),
+		StateFile:       path,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	data, err := readStateFile(path)
+	if err != nil {
+		t.Fatalf("readStateFile() failed: %v", err)
+	}
+	if len(data.Processes) != 1 {
+		t.Fatalf("Expected one recorded instance, got %+v", data.Processes)
+	}
+	entry := data.Processes[0]
+	current, err := readProcessIdentity(gr.GetPID())
+	if err != nil {
+		t.Fatalf("readProcessIdentity() failed: %v", err)
+	}
+	if !entry.sameProcess(current) || entry.StartTime == 0 || entry.BootID == "" || !strings.HasSuffix(entry.Executable, "/sleep") {
+		t.Errorf("Recorded %+v, the process is %+v", entry, current)
+	}
+
+	if err := gr.StopProgram(); err != nil {
+		t.Fatalf("StopProgram() failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("State file should be removed once no instance runs, got %v", err)
+	}
+}
+
+func TestStateFile_StopsOrphanOfCrashedHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sh.json")
+	host, pids := startHost(t, "state="+path)
+	// The background child is not recorded, only the program itself
+	defer func() {
+		for _, pid := range pids {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}()
+
+	host.Process.Signal(syscall.SIGKILL)
+	host.Wait()
+	if processGone(pids[0]) {
+		t.Fatal("The program should outlive its crashed host")
+	}
+
+	gr := New(&Config{
+		ExecProgramPath: "/bin/sh",
+		RunArguments:    func() []string { return []string{"-c", "sleep 30"} },
+		ExitChan:        make(chan bool),
+		StateFile:       path,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	if !processGone(pids[0]) {
+		t.Errorf("Orphan %d was not stopped", pids[0])
+	}
+	if !strings.Contains(gr.getOutput(), "Stopped orphan") {
+		t.Errorf("Expected the orphan stop to be logged, got %q", gr.getOutput())
+	}
+
+	data, _ := readStateFile(path)
+	if len(data.Processes) != 1 || data.Processes[0].PID != gr.GetPID() {
+		t.Errorf("Only the new instance should be recorded, got %+v", data.Processes)
+	}
+}
+
+func TestStateFile_LeavesOtherProcesses(t *testing.T) {
+	// Same executable as the program, but not the recorded process
+	other := exec.Command("sleep", "30")
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Wait()
+	defer other.Process.Kill()
+
+	identity, err := readProcessIdentity(other.Process.Pid)
+	if err != nil {
+		t.Fatalf("readProcessIdentity() failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(e *stateFileEntry)
+	}{
+		{"reused PID", func(e *stateFileEntry) { e.StartTime++ }},
+		{"other boot", func(e *stateFileEntry) { e.BootID = "00000000-0000-0000-0000-000000000000" }},
+		{"other arguments", func(e *stateFileEntry) { e.ArgsHash = strings.Repeat("0", 64) }},
+		{"other executable", func(e *stateFileEntry) { e.Executable = "/usr/bin/other" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			entry := identity
+			tt.change(&entry)
+			if err := writeStateFile(path, stateFileData{Processes: []stateFileEntry{entry}}); err != nil {
+				t.Fatal(err)
+			}
+
+			gr := New(&Config{
+				ExecProgramPath: "sleep",
+				RunArguments:    func() []string { return []string{"30"} },
+				ExitChan:        make(chan bool),
+				StateFile:       path,
+				StopTimeout:     100 * time.Millisecond,
+			})
+			defer gr.StopProgram()
+
+			if err := gr.RunProgram(); err != nil {
+				t.Fatalf("RunProgram() failed: %v", err)
+			}
+			if processGone(other.Process.Pid) {
+				t.Fatal("A process not matching the state file was stopped")
+			}
+			data, _ := readStateFile(path)
+			if len(data.Processes) != 1 || data.Processes[0].PID != gr.GetPID() {
+				t.Errorf("The stale entry should be dropped, got %+v", data.Processes)
+			}
+		})
+	}
+}
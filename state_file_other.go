@@ -0,0 +1,10 @@
+//go:build !linux
+
+package gorun
+
+import "errors"
+
+// readProcessIdentity is not supported: without it no orphan is ever stopped
+func readProcessIdentity(pid int) (stateFileEntry, error) {
+	return stateFileEntry{}, errors.New("state files are only supported on Linux")
+}
@@ -0,0 +1,137 @@
+package gorun
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ResourceStats is a resource usage sample of the running program
+type ResourceStats struct {
+	PID        int           `json:"pid"`
+	Time       time.Time     `json:"time"`
+	Processes  int           `json:"processes"`   // Processes included, more than 1 with Config.StatsProcessTree
+	CPUPercent float64       `json:"cpu_percent"` // Since the previous sample, 100 is one full core
+	CPUTime    time.Duration `json:"cpu_time"`    // User plus system time
+	RSS        uint64        `json:"rss"`         // Resident memory, bytes
+	VMS        uint64        `json:"vms"`         // Virtual memory, bytes
+	Threads    int           `json:"threads"`
+	FDs        int           `json:"fds"`         // Open file descriptors
+	ReadBytes  uint64        `json:"read_bytes"`  // Bytes read through syscalls
+	WriteBytes uint64        `json:"write_bytes"` // Bytes written through syscalls
+}
+
+// procUsage is the raw usage read for one process or a process tree
+type procUsage struct {
+	processes  int
+	cpuTime    time.Duration
+	rss, vms   uint64
+	threads    int
+	fds        int
+	readBytes  uint64
+	writeBytes uint64
+}
+
+// defaultStatsInterval is used by WatchStats when interval is not positive
+const defaultStatsInterval = time.Second
+
+// statsSampler turns cumulative CPU time into a percentage between samples
+type statsSampler struct {
+	mutex   sync.Mutex
+	pid     int
+	cpuTime time.Duration
+	time    time.Time
+}
+
+// sample reads the usage of pid and computes CPU% against the previous sample
+// of the same pid, or against startTime for the first one
+func (s *statsSampler) sample(pid int, startTime time.Time, tree bool) (ResourceStats, error) {
+	usage, err := readProcUsage(pid, tree)
+	if err != nil {
+		return ResourceStats{}, err
+	}
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prevCPU, prevTime := time.Duration(0), startTime
+	if s.pid == pid {
+		prevCPU, prevTime = s.cpuTime, s.time
+	}
+	s.pid, s.cpuTime, s.time = pid, usage.cpuTime, now
+
+	stats := ResourceStats{
+		PID:        pid,
+		Time:       now,
+		Processes:  usage.processes,
+		CPUTime:    usage.cpuTime,
+		RSS:        usage.rss,
+		VMS:        usage.vms,
+		Threads:    usage.threads,
+		FDs:        usage.fds,
+		ReadBytes:  usage.readBytes,
+		WriteBytes: usage.writeBytes,
+	}
+	// Children exiting make the tree CPU time go down, report 0 then
+	if elapsed := now.Sub(prevTime); elapsed > 0 && usage.cpuTime > prevCPU {
+		stats.CPUPercent = 100 * float64(usage.cpuTime-prevCPU) / float64(elapsed)
+	}
+	return stats, nil
+}
+
+// activeProcessStart returns the PID and start time of the active process, 0 if stopped
+func (h *GoRun) activeProcessStart() (int, time.Time) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if !h.isRunning || h.Cmd.Process == nil {
+		return 0, time.Time{}
+	}
+	return h.programPID(h.Cmd.Process.Pid), h.startTime
+}
+
+// Stats samples the resource usage of the running program. CPUPercent is
+// measured since the previous Stats call. Only supported on Linux.
+func (h *GoRun) Stats() (ResourceStats, error) {
+	pid, startTime := h.activeProcessStart()
+	if pid == 0 {
+		return ResourceStats{}, ErrNotRunning
+	}
+	return h.statsSampler.sample(pid, startTime, h.StatsProcessTree)
+}
+
+// WatchStats samples the resource usage every interval (default: 1s) until ctx
+// is done, then closes the returned channel. Intervals where the program is not
+// running are skipped, so are samples the receiver is not ready for.
+func (h *GoRun) WatchStats(ctx context.Context, interval time.Duration) <-chan ResourceStats {
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	samples := make(chan ResourceStats, 1)
+
+	go func() {
+		defer close(samples)
+		var sampler statsSampler
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if pid, startTime := h.activeProcessStart(); pid != 0 {
+				if stats, err := sampler.sample(pid, startTime, h.StatsProcessTree); err == nil {
+					select {
+					case samples <- stats:
+					default:
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return samples
+}
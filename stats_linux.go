@@ -0,0 +1,159 @@
+package gorun
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the /proc/<pid>/stat times. It is 100 on
+// every Linux architecture Go supports.
+const clockTicks = 100
+
+// readProcUsage reads the usage of pid, summed over its descendants if tree is set
+func readProcUsage(pid int, tree bool) (procUsage, error) {
+	pids := []int{pid}
+	if tree {
+		pids = processTree(pid)
+	}
+
+	var total procUsage
+	for i, p := range pids {
+		u, err := readOneProcUsage(p)
+		if err != nil {
+			if i == 0 {
+				return procUsage{}, err
+			}
+			continue // A descendant exited meanwhile
+		}
+		total.processes++
+		total.cpuTime += u.cpuTime
+		total.rss += u.rss
+		total.vms += u.vms
+		total.threads += u.threads
+		total.fds += u.fds
+		total.readBytes += u.readBytes
+		total.writeBytes += u.writeBytes
+	}
+	return total, nil
+}
+
+// readOneProcUsage reads /proc/<pid>/stat, status, io and fd
+func readOneProcUsage(pid int) (procUsage, error) {
+	dir := fmt.Sprintf("/proc/%d/", pid)
+
+	stat, err := readProcStat(pid)
+	if err != nil {
+		return procUsage{}, err
+	}
+	u := procUsage{
+		cpuTime: time.Duration(stat.utime+stat.stime) * time.Second / clockTicks,
+		vms:     stat.vsize,
+		threads: stat.threads,
+	}
+
+	// Zombies have no VmRSS and the io file may be restricted: missing values stay 0
+	if status, err := readProcKeyValues(dir + "status"); err == nil {
+		u.rss = parseUint(strings.TrimSuffix(status["VmRSS"], " kB")) * 1024
+	}
+	if io, err := readProcKeyValues(dir + "io"); err == nil {
+		u.readBytes = parseUint(io["rchar"])
+		u.writeBytes = parseUint(io["wchar"])
+	}
+	if fds, err := os.ReadDir(dir + "fd"); err == nil {
+		u.fds = len(fds)
+	}
+	return u, nil
+}
+
+// procStat holds the /proc/<pid>/stat fields gorun uses
+type procStat struct {
+	state        string
+	ppid         int
+	utime, stime uint64
+	threads      int
+	startTime    uint64 // Clock ticks since boot
+	vsize        uint64
+}
+
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	// The command name may contain spaces and parentheses, fields start after the last ')'
+	s := string(data)
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return procStat{}, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	// fields[0] is field 3 (state) of proc(5)
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+
+	ppid, _ := strconv.Atoi(fields[1])
+	threads, _ := strconv.Atoi(fields[17])
+	return procStat{
+		state:     fields[0],
+		ppid:      ppid,
+		utime:     parseUint(fields[11]),
+		stime:     parseUint(fields[12]),
+		threads:   threads,
+		startTime: parseUint(fields[19]),
+		vsize:     parseUint(fields[20]),
+	}, nil
+}
+
+// readProcKeyValues parses "Key: value" lines like /proc/<pid>/status and io
+func readProcKeyValues(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), ":"); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// processTree returns pid followed by all its descendants
+func processTree(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return []int{pid}
+	}
+
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if stat, err := readProcStat(child); err == nil {
+			children[stat.ppid] = append(children[stat.ppid], child)
+		}
+	}
+
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
+
+func parseUint(s string) uint64 {
+	v, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return v
+}
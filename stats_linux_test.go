@@ -0,0 +1,81 @@
+package gorun
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStats_Sample(t *testing.T) {
+	execPath := buildTestProgram(t, "long_program")
+	defer os.Remove(execPath)
+
+	gr := New(&Config{ExecProgramPath: execPath, ExitChan: make(chan bool)})
+	defer gr.StopProgram()
+
+	if _, err := gr.Stats(); err == nil {
+		t.Error("Stats() should fail when the program is not running")
+	}
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	stats, err := gr.Stats()
+	if err != nil {
+		t.Fatalf("Stats() failed: %v", err)
+	}
+	if stats.PID != gr.GetPID() || stats.Processes != 1 {
+		t.Errorf("Unexpected PID/processes: %+v", stats)
+	}
+	if stats.RSS == 0 || stats.VMS < stats.RSS || stats.Threads < 1 || stats.FDs < 3 || stats.WriteBytes == 0 {
+		t.Errorf("Implausible sample: %+v", stats)
+	}
+	if stats.CPUPercent < 0 {
+		t.Errorf("Negative CPU percent: %+v", stats)
+	}
+}
+
+func TestStats_ProcessTree(t *testing.T) {
+	gr := New(&Config{
+		ExecProgramPath:  "sh",
+		RunArguments:     func() []string { return []string{"-c", "sleep 5 & sleep 5; wait"} },
+		ExitChan:         make(chan bool),
+		StatsProcessTree: true,
+	})
+	defer gr.StopProgram()
+
+	if err := gr.RunProgram(); err != nil {
+		t.Fatalf("RunProgram() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples := gr.WatchStats(ctx, 50*time.Millisecond)
+
+	deadline := time.After(3 * time.Second)
+	for {
+		select {
+		case stats := <-samples:
+			if stats.Processes >= 3 {
+				cancel()
+				for range samples {
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("WatchStats() never reported the shell and its two children")
+		}
+	}
+}
+
+func TestReadProcStat(t *testing.T) {
+	stat, err := readProcStat(os.Getpid())
+	if err != nil {
+		t.Fatalf("readProcStat() failed: %v", err)
+	}
+	if stat.ppid != os.Getppid() || stat.threads < 1 {
+		t.Errorf("Unexpected stat of the test process: %+v", stat)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package gorun
+
+import "errors"
+
+// readProcUsage needs /proc, resource stats are Linux only
+func readProcUsage(pid int, tree bool) (procUsage, error) {
+	return procUsage{}, errors.New("resource stats are only supported on Linux")
+}
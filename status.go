@@ -0,0 +1,62 @@
+package gorun
+
+import (
+	"time"
+)
+
+// Program states reported by Status
+const (
+	StateStopped  = "stopped"  // No process is running
+	StateStarting = "starting" // The process runs but did not pass its ReadinessProbe yet
+	StateRunning  = "running"  // The process runs and is ready
+)
+
+// Status is a snapshot of a GoRun, encoded as is by the control API
+type Status struct {
+	State     string        `json:"state"`
+	PID       int           `json:"pid,omitempty"`
+	StartTime time.Time     `json:"start_time,omitzero"` // Start of the active process, zero when stopped
+	Uptime    time.Duration `json:"uptime,omitempty"`
+	Restarts  int           `json:"restarts"` // Automatic restarts done by the RestartPolicy
+	LastExit  *ExitInfo     `json:"last_exit,omitempty"`
+	Attached  bool          `json:"attached,omitempty"`   // The process was attached: no output is captured
+	Races     int           `json:"races,omitempty"`      // Distinct data races reported, see Races
+	DebugAddr string        `json:"debug_addr,omitempty"` // Address of the debug server, see DebugConfig
+}
+
+// Status returns the current state of the program
+func (h *GoRun) Status() Status {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	s := Status{State: StateStopped, Restarts: h.restarts, Races: h.races.len()}
+	if h.lastExit != nil {
+		info := *h.lastExit
+		s.LastExit = &info
+	}
+	if !h.isRunning || h.Cmd.Process == nil {
+		return s
+	}
+
+	s.State = StateRunning
+	if !h.ready.isReady() {
+		s.State = StateStarting
+	}
+	s.PID = h.programPID(h.Cmd.Process.Pid)
+	s.Attached = h.attached
+	s.DebugAddr = h.activeDebugAddrUnsafe()
+	s.StartTime = h.startTime
+	s.Uptime = time.Since(h.startTime)
+	return s
+}
+
+// OutputTail returns the last lines of the captured output
+func (h *GoRun) OutputTail(lines int) string {
+	return h.safeBuffer.Tail(lines)
+}
+
+// FollowOutput returns the last lines of the captured output and a channel
+// receiving the output written afterwards, see SafeBuffer.Follow
+func (h *GoRun) FollowOutput(lines int) (tail string, output <-chan []byte, cancel func()) {
+	return h.safeBuffer.Follow(lines)
+}
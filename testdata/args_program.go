@@ -10,6 +10,9 @@ import (
 func main() {
 	fmt.Println("ARGS_PROGRAM_STARTED")
 	fmt.Println("ARGS:" + strings.Join(os.Args[1:], ","))
+	if port := os.Getenv("HTTP_PORT"); port != "" {
+		fmt.Println("HTTP_PORT:" + port)
+	}
 
 	// Keep it alive for a short time to allow testing
 	time.Sleep(50 * time.Millisecond)
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Built with -cover: returns from main on SIGTERM so its coverage counters are written
+func main() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+
+	if len(os.Args) > 1 && os.Args[1] == "first" {
+		fmt.Println("COVER_PROGRAM_FIRST")
+	} else {
+		fmt.Println("COVER_PROGRAM_SECOND")
+	}
+	fmt.Println("COVER_PROGRAM_GOCOVERDIR", os.Getenv("GOCOVERDIR"))
+
+	<-stop
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// A stand-in for "dlv exec --headless": starts the program, listens on --listen
+// and kills the program on SIGINT. FAKE_DLV_IGNORE_INT=1 ignores SIGINT instead.
+func main() {
+	var addr string
+	var rest []string
+	for i, arg := range os.Args[2:] {
+		if strings.HasPrefix(arg, "--listen=") {
+			addr = strings.TrimPrefix(arg, "--listen=")
+		}
+		if !strings.HasPrefix(arg, "--") {
+			rest = os.Args[2+i:]
+			break
+		}
+	}
+	fmt.Println("FAKE_DLV_ARGS", strings.Join(os.Args[1:], " "))
+
+	interrupt := make(chan os.Signal, 1)
+	if os.Getenv("FAKE_DLV_IGNORE_INT") == "1" {
+		signal.Ignore(syscall.SIGINT)
+	} else {
+		signal.Notify(interrupt, syscall.SIGINT)
+	}
+
+	program := exec.Command(rest[0], rest[2:]...)
+	program.Stdout = os.Stdout
+	program.Stderr = os.Stderr
+	if err := program.Start(); err != nil {
+		fmt.Println("FAKE_DLV_ERROR", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println("FAKE_DLV_ERROR", err)
+		program.Process.Kill()
+		os.Exit(1)
+	}
+	fmt.Println("API server listening at:", listener.Addr())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	<-interrupt
+	program.Process.Kill()
+	program.Wait()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// waitForever blocks the goroutine it runs in, it shows up in the stack dump
+func waitForever(ch chan struct{}) {
+	<-ch
+}
+
+func main() {
+	go waitForever(make(chan struct{}))
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			fmt.Println("GOROUTINES_PROGRAM_RELOADED")
+		}
+	}()
+
+	fmt.Println("GOROUTINES_PROGRAM_STARTED")
+	time.Sleep(time.Minute)
+}
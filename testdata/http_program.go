@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := "127.0.0.1:8080"
+	if len(os.Args) > 1 {
+		addr = os.Args[1]
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "HTTP_PROGRAM_%d", os.Getpid())
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, " %s", body)
+		}
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		server.Shutdown(context.Background())
+	}()
+
+	fmt.Println("HTTP_PROGRAM_STARTED")
+	// Listens late, eg: to be reached before it listens
+	if delay, err := time.ParseDuration(os.Getenv("HTTP_PROGRAM_LISTEN_DELAY")); err == nil {
+		time.Sleep(delay)
+	}
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println("HTTP_PROGRAM_ERROR:", err)
+		os.Exit(1)
+	}
+	fmt.Println("HTTP_PROGRAM_FINISHED")
+}
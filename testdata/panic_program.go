@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+// lookup fails with an index out of range, the frame the crash points to
+func lookup(values []int, i int) int {
+	return values[i]
+}
+
+func main() {
+	fmt.Println("PANIC_PROGRAM_STARTED")
+	fmt.Println(lookup([]int{1, 2, 3}, 3))
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Built with -race: the counter is written by two goroutines without synchronization
+func main() {
+	counter := 0
+	go func() { counter++ }()
+	counter++
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println("RACE_PROGRAM_COUNTER", counter)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// Reports what the program sees of its environment, one KEY=value per line
+func main() {
+	fmt.Printf("PPID=%d\n", os.Getppid())
+
+	hostname, _ := os.Hostname()
+	fmt.Printf("HOSTNAME=%s\n", hostname)
+
+	interfaces, _ := net.Interfaces()
+	for _, iface := range interfaces {
+		fmt.Printf("INTERFACE=%s %s\n", iface.Name, iface.Flags)
+	}
+
+	if l, err := net.Listen("tcp", "127.0.0.1:0"); err == nil {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		fmt.Printf("LOOPBACK=%v\n", err == nil)
+		if err == nil {
+			conn.Close()
+		}
+		l.Close()
+	} else {
+		fmt.Printf("LOOPBACK=false\n")
+	}
+
+	err := os.WriteFile("sandbox_write_test", []byte("x"), 0644)
+	fmt.Printf("WORKDIR_WRITABLE=%v\n", err == nil)
+	os.Remove("sandbox_write_test")
+
+	entries, _ := os.ReadDir("/tmp")
+	fmt.Printf("TMP_ENTRIES=%d\n", len(entries))
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	// Ignore graceful stop requests, only a kill ends this program
+	signal.Ignore(syscall.SIGTERM, syscall.SIGINT)
+
+	fmt.Println("STUBBORN_PROGRAM_STARTED")
+	for {
+		time.Sleep(time.Second)
+	}
+}